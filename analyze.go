@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jayapriya90/k8s-pod-monitor/v1alpha1"
+	core_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/yaml"
+)
+
+// auditEvent holds the fields of an audit.k8s.io Event needed to replay pod changes.
+// Pod objects are only present in audit logs written at the RequestResponse level
+type auditEvent struct {
+	AuditID   string `json:"auditID"`
+	Stage     string `json:"stage"`
+	Verb      string `json:"verb"`
+	ObjectRef *struct {
+		Resource    string `json:"resource"`
+		Namespace   string `json:"namespace"`
+		Name        string `json:"name"`
+		Subresource string `json:"subresource"`
+	} `json:"objectRef"`
+	ResponseStatus *meta_v1.Status `json:"responseStatus"`
+	ResponseObject json.RawMessage `json:"responseObject"`
+}
+
+// runAnalyze implements the analyze subcommand. Every argument is a PodList dump
+// (`kubectl get pods -o json|yaml`), an audit log or a directory of them. Dumps
+// are replayed in order through PodTracker and the resulting status is printed
+func runAnalyze(args []string) error {
+	flags := flag.NewFlagSet("analyze", flag.ExitOnError)
+	output := flags.String("o", "table", "output format: table or json")
+	started := flags.String("started", "", "RFC3339 time the monitor would have started at. Pending pods created before it are ignored")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s analyze [flags] FILE|DIR...\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return fmt.Errorf("no pod dump given")
+	}
+	if *output != "table" && *output != "json" {
+		return fmt.Errorf("unknown output format %q", *output)
+	}
+	var startedTs time.Time
+	if *started != "" {
+		var err error
+		if startedTs, err = time.Parse(time.RFC3339, *started); err != nil {
+			return fmt.Errorf("invalid -started: %v", err)
+		}
+	}
+
+	files, err := collectDumpFiles(flags.Args())
+	if err != nil {
+		return err
+	}
+
+	tracker := NewPodTracker(startedTs)
+	for _, file := range files {
+		if err := replayDumpFile(tracker, file); err != nil {
+			return fmt.Errorf("%s: %v", file, err)
+		}
+	}
+
+	status := tracker.Status()
+	if *output == "json" {
+		return printJSON(os.Stdout, status)
+	}
+	printStatusTable(os.Stdout, status)
+	return nil
+}
+
+// collectDumpFiles expands directories into the dump files they contain, sorted
+// by name so that timestamped file names are replayed in the order they were taken
+func collectDumpFiles(paths []string) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		entries, err := ioutil.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+		var dirFiles []string
+		for _, entry := range entries {
+			switch filepath.Ext(entry.Name()) {
+			case ".json", ".yaml", ".yml", ".log":
+				dirFiles = append(dirFiles, filepath.Join(path, entry.Name()))
+			}
+		}
+		sort.Strings(dirFiles)
+		files = append(files, dirFiles...)
+	}
+	return files, nil
+}
+
+// replayDumpFile feeds a single dump into the tracker. Audit logs are detected by
+// an auditID on their first line, everything else is decoded as a PodList
+func replayDumpFile(tracker *PodTracker, file string) error {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+
+	firstLine := data
+	if i := bytes.IndexByte(data, '\n'); i >= 0 {
+		firstLine = data[:i]
+	}
+	var probe auditEvent
+	if json.Unmarshal(firstLine, &probe) == nil && probe.AuditID != "" {
+		return replayAuditLog(tracker, bytes.NewReader(data))
+	}
+
+	var podList core_v1.PodList
+	if err := yaml.Unmarshal(data, &podList); err != nil {
+		return err
+	}
+	replaySnapshot(tracker, podList.Items)
+	return nil
+}
+
+// replaySnapshot treats a pod list as the full cluster state at one point in time.
+// Pods tracked from a previous snapshot and missing from this one are considered deleted
+func replaySnapshot(tracker *PodTracker, pods []core_v1.Pod) {
+	seen := make(map[string]bool)
+	for i := range pods {
+		key, err := cache.MetaNamespaceKeyFunc(&pods[i])
+		if err != nil {
+			continue
+		}
+		seen[key] = true
+		tracker.Observe(key, &pods[i])
+	}
+	for _, key := range tracker.TrackedKeys() {
+		if !seen[key] {
+			tracker.Forget(key)
+		}
+	}
+}
+
+// replayAuditLog applies completed pod create, update, patch and delete requests in log order
+func replayAuditLog(tracker *PodTracker, r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var event auditEvent
+		if err := json.Unmarshal(line, &event); err != nil {
+			return err
+		}
+		if event.Stage != "ResponseComplete" || event.ObjectRef == nil || event.ObjectRef.Resource != "pods" {
+			continue
+		}
+		if event.ResponseStatus != nil && event.ResponseStatus.Code >= 300 {
+			continue
+		}
+		key := event.ObjectRef.Namespace + "/" + event.ObjectRef.Name
+		switch event.Verb {
+		case "delete":
+			tracker.Forget(key)
+		case "create", "update", "patch":
+			if len(event.ResponseObject) == 0 {
+				continue
+			}
+			pod := &core_v1.Pod{}
+			if err := json.Unmarshal(event.ResponseObject, pod); err != nil || pod.Kind != "Pod" {
+				continue
+			}
+			// pods created through generateName only get their name in the response
+			if event.ObjectRef.Name == "" {
+				key = pod.Namespace + "/" + pod.Name
+			}
+			tracker.Observe(key, pod)
+		}
+	}
+	return scanner.Err()
+}
+
+func printJSON(w io.Writer, v interface{}) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+func printStatusTable(w io.Writer, status v1alpha1.PodMonitorStatus) {
+	fmt.Fprintf(w, "podCreatedCount: %d\n", status.PodCreatedCount)
+	fmt.Fprintf(w, "podRunningCount: %d\n\n", status.PodRunningCount)
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAMESPACE\tCREATED\tRUNNING\tPENDING\tFAILED")
+	for _, ns := range status.Namespaces {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\n", ns.Namespace, ns.PodCreatedCount, ns.PodRunningCount, ns.PodPendingCount, ns.PodFailedCount)
+	}
+	tw.Flush()
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	core_v1 "k8s.io/api/core/v1"
+)
+
+func TestReplaySnapshotBreakdown(t *testing.T) {
+	now := time.Now()
+	tracker := NewPodTracker(time.Time{})
+
+	replaySnapshot(tracker, []core_v1.Pod{
+		newTestPod("default", "a", core_v1.PodPending, now),
+		newTestPod("default", "b", core_v1.PodPending, now),
+		newTestPod("kube-system", "c", core_v1.PodRunning, now),
+	})
+	// b is gone from the second snapshot and a started running
+	replaySnapshot(tracker, []core_v1.Pod{
+		newTestPod("default", "a", core_v1.PodRunning, now),
+		newTestPod("kube-system", "c", core_v1.PodRunning, now),
+		newTestPod("kube-system", "d", core_v1.PodFailed, now),
+	})
+
+	status := tracker.Status()
+	require.Equal(t, int32(2), status.PodCreatedCount)
+	require.Equal(t, int32(2), status.PodRunningCount)
+	require.Len(t, status.Namespaces, 2)
+
+	require.Equal(t, "default", status.Namespaces[0].Namespace)
+	require.Equal(t, int32(2), status.Namespaces[0].PodCreatedCount)
+	require.Equal(t, int32(1), status.Namespaces[0].PodRunningCount)
+	require.Equal(t, int32(0), status.Namespaces[0].PodPendingCount)
+
+	require.Equal(t, "kube-system", status.Namespaces[1].Namespace)
+	require.Equal(t, int32(1), status.Namespaces[1].PodRunningCount)
+	require.Equal(t, int32(1), status.Namespaces[1].PodFailedCount)
+}
@@ -0,0 +1,407 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	core_v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	// defaultPageLimit is the page size used when a request does not set limit
+	defaultPageLimit = 500
+	// maxPageLimit caps the page size a client can ask for
+	maxPageLimit = 5000
+)
+
+// APIServer serves a read-only JSON API over the pods in the informer indexer
+// and the state kept by the pod handler, so tools can query the controller
+// instead of listing pods from the API server
+type APIServer struct {
+	informer   cache.SharedIndexInformer
+	handler    *PodHandler
+	tracker    *PodTracker
+	broker     *EventBroker
+	stuckAfter time.Duration
+	mux        *http.ServeMux
+}
+
+// PodRecord is the representation of a pod returned by /api/v1/pods
+type PodRecord struct {
+	Namespace  string            `json:"namespace"`
+	Name       string            `json:"name"`
+	Phase      core_v1.PodPhase  `json:"phase"`
+	State      string            `json:"state"`
+	NodeName   string            `json:"nodeName,omitempty"`
+	Owner      string            `json:"owner,omitempty"`
+	Labels     map[string]string `json:"labels,omitempty"`
+	Lifecycle  *PodLifecycle     `json:"lifecycle,omitempty"`
+	Containers []ContainerRecord `json:"containers,omitempty"`
+	Security   *PodSecurity      `json:"security,omitempty"`
+}
+
+// PodPage is a page of pods. Continue is set when more pods are available and
+// has to be passed back as the continue parameter to fetch the next page
+type PodPage struct {
+	Items    []PodRecord `json:"items"`
+	Continue string      `json:"continue,omitempty"`
+}
+
+// Counts is the response of /api/v1/counts. The created count covers every pod
+// created since the monitor started, the other counts cover the current pods
+type Counts struct {
+	PodCreatedCount   int `json:"podCreatedCount"`
+	PodRunningCount   int `json:"podRunningCount"`
+	PodPendingCount   int `json:"podPendingCount"`
+	PodSucceededCount int `json:"podSucceededCount"`
+	PodFailedCount    int `json:"podFailedCount"`
+	PodStuckCount     int `json:"podStuckCount"`
+}
+
+// podFilter holds the filters shared by the query endpoints
+type podFilter struct {
+	namespace string
+	selector  labels.Selector
+	phase     core_v1.PodPhase
+	state     string
+}
+
+// NewAPIServer initialization
+func NewAPIServer(informer cache.SharedIndexInformer, handler *PodHandler) *APIServer {
+	s := &APIServer{
+		informer:   informer,
+		handler:    handler,
+		tracker:    handler.tracker,
+		broker:     handler.options.Broker,
+		stuckAfter: handler.options.StuckAfter,
+		mux:        http.NewServeMux(),
+	}
+	s.mux.HandleFunc("/", s.handleDashboard)
+	s.mux.HandleFunc("/metrics", s.handleMetrics)
+	s.mux.HandleFunc("/api/v1/status", s.handleStatus)
+	s.mux.HandleFunc("/api/v1/alerts", s.handleAlerts)
+	s.mux.HandleFunc("/api/v1/counts", s.handleCounts)
+	s.mux.HandleFunc("/api/v1/pods", s.handlePods)
+	s.mux.HandleFunc("/api/v1/stream", s.handleStream)
+	s.mux.HandleFunc("/api/v1/policy", s.handlePolicy)
+	s.mux.HandleFunc("/api/v1/network-coverage", s.handleNetworkCoverage)
+	s.mux.HandleFunc("/api/v1/missing-references", s.handleMissingReferences)
+	s.mux.HandleFunc("/api/v1/images", s.handleImages)
+	s.mux.HandleFunc("/api/v1/history", s.handleHistory)
+	return s
+}
+
+// ServeHTTP implements http.Handler
+func (s *APIServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+// ListenAndServe serves the API on addr until the server fails
+func (s *APIServer) ListenAndServe(addr string) {
+	log.Infof("Serving query API on %s", addr)
+	if err := http.ListenAndServe(addr, s); err != nil {
+		log.Errorf("query API stopped: %v", err)
+	}
+}
+
+// handleStatus serves /api/v1/status, the status of the pod-monitor resource
+func (s *APIServer) handleStatus(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, s.handler.Status())
+}
+
+// handleAlerts serves /api/v1/alerts, the conditions of the pod-monitor resource with status True
+func (s *APIServer) handleAlerts(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, activeConditions(s.handler.Status().Conditions))
+}
+
+// handlePolicy serves /api/v1/policy?namespace=, the pods violating the policy of the spec
+func (s *APIServer) handlePolicy(w http.ResponseWriter, r *http.Request) {
+	namespace := r.URL.Query().Get("namespace")
+	violations := []PolicyViolation{}
+	for _, violation := range s.handler.PolicyViolations() {
+		if namespace == "" || violation.Namespace == namespace {
+			violations = append(violations, violation)
+		}
+	}
+	writeJSON(w, violations)
+}
+
+// handleNetworkCoverage serves /api/v1/network-coverage?namespace=, the
+// running pods not selected by any NetworkPolicy for ingress or egress
+func (s *APIServer) handleNetworkCoverage(w http.ResponseWriter, r *http.Request) {
+	all := s.handler.UnprotectedPods()
+	if all == nil {
+		http.Error(w, "NetworkPolicy coverage is disabled", http.StatusNotFound)
+		return
+	}
+	namespace := r.URL.Query().Get("namespace")
+	pods := []UnprotectedPod{}
+	for _, pod := range all {
+		if namespace == "" || pod.Namespace == namespace {
+			pods = append(pods, pod)
+		}
+	}
+	writeJSON(w, pods)
+}
+
+// handleMissingReferences serves /api/v1/missing-references?namespace=, the
+// waiting pods referencing ConfigMaps, Secrets, PVCs or ServiceAccounts that do not exist
+func (s *APIServer) handleMissingReferences(w http.ResponseWriter, r *http.Request) {
+	namespace := r.URL.Query().Get("namespace")
+	pods := []BlockedPod{}
+	for _, pod := range s.handler.BlockedPods() {
+		if namespace == "" || pod.Namespace == namespace {
+			pods = append(pods, pod)
+		}
+	}
+	writeJSON(w, pods)
+}
+
+// handleImages serves /api/v1/images?namespace=&registry=&format=csv, the
+// inventory of the images used by running pods
+func (s *APIServer) handleImages(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	inventory := imageInventory(s.listPods(query.Get("namespace")))
+	if registry := query.Get("registry"); registry != "" {
+		images := []ImageRecord{}
+		for _, record := range inventory.Images {
+			if record.Registry == registry {
+				images = append(images, record)
+			}
+		}
+		inventory.Images = images
+	}
+	switch query.Get("format") {
+	case "", "json":
+		writeJSON(w, inventory)
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv")
+		if err := writeImagesCSV(w, inventory); err != nil {
+			log.Errorf("failed to write response: %v", err)
+		}
+	default:
+		http.Error(w, "format must be json or csv", http.StatusBadRequest)
+	}
+}
+
+// handleHistory serves /api/v1/history?resolution=minute|hour&window=, the
+// buckets of pod changes within window, oldest first. window defaults to one
+// hour of minutes or a day of hours
+func (s *APIServer) handleHistory(w http.ResponseWriter, r *http.Request) {
+	if s.handler.options.History == nil {
+		http.Error(w, "history is disabled", http.StatusNotFound)
+		return
+	}
+	query := r.URL.Query()
+	resolution, window := query.Get("resolution"), time.Hour
+	switch resolution {
+	case "", historyMinute:
+		resolution = historyMinute
+	case historyHour:
+		window = 24 * time.Hour
+	default:
+		http.Error(w, "resolution must be minute or hour", http.StatusBadRequest)
+		return
+	}
+	if value := query.Get("window"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed <= 0 {
+			http.Error(w, "invalid window", http.StatusBadRequest)
+			return
+		}
+		window = parsed
+	}
+	writeJSON(w, HistoryPage{Resolution: resolution, Buckets: s.handler.options.History.Buckets(resolution, time.Now().Add(-window))})
+}
+
+// handleCounts serves /api/v1/counts?namespace=&label=&phase=
+func (s *APIServer) handleCounts(w http.ResponseWriter, r *http.Request) {
+	filter, err := parsePodFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writeJSON(w, s.counts(filter, time.Now()))
+}
+
+// counts computes the counts of the pods matching filter
+func (s *APIServer) counts(filter podFilter, now time.Time) Counts {
+	counts := Counts{PodCreatedCount: s.tracker.CreatedCountMatching(filter.namespace, filter.selector)}
+	for _, pod := range s.listPods(filter.namespace) {
+		state := s.podState(pod, now)
+		if !filter.matches(pod, state) {
+			continue
+		}
+		switch pod.Status.Phase {
+		case core_v1.PodRunning:
+			counts.PodRunningCount++
+		case core_v1.PodPending:
+			counts.PodPendingCount++
+		case core_v1.PodSucceeded:
+			counts.PodSucceededCount++
+		case core_v1.PodFailed:
+			counts.PodFailedCount++
+		}
+		if state == "stuck" {
+			counts.PodStuckCount++
+		}
+	}
+	return counts
+}
+
+// handlePods serves /api/v1/pods?namespace=&label=&phase=&state=&limit=&continue=
+// Pods are returned sorted by namespace/name
+func (s *APIServer) handlePods(w http.ResponseWriter, r *http.Request) {
+	filter, err := parsePodFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	limit := defaultPageLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		if limit, err = strconv.Atoi(value); err != nil || limit <= 0 {
+			http.Error(w, fmt.Sprintf("invalid limit %q", value), http.StatusBadRequest)
+			return
+		}
+		if limit > maxPageLimit {
+			limit = maxPageLimit
+		}
+	}
+	// continue holds the key of the last pod of the previous page
+	after := r.URL.Query().Get("continue")
+
+	now := time.Now()
+	var records []PodRecord
+	for _, pod := range s.listPods(filter.namespace) {
+		state := s.podState(pod, now)
+		if !filter.matches(pod, state) {
+			continue
+		}
+		records = append(records, s.podRecord(pod, state))
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return recordKey(records[i]) < recordKey(records[j])
+	})
+
+	start := sort.Search(len(records), func(i int) bool {
+		return recordKey(records[i]) > after
+	})
+	page := PodPage{Items: records[start:]}
+	if len(page.Items) > limit {
+		page.Items = page.Items[:limit]
+		page.Continue = recordKey(page.Items[limit-1])
+	}
+	if page.Items == nil {
+		page.Items = []PodRecord{}
+	}
+	writeJSON(w, page)
+}
+
+// listPods returns the pods in the informer indexer, using the namespace index when a namespace is given
+func (s *APIServer) listPods(namespace string) []*core_v1.Pod {
+	var objs []interface{}
+	if namespace == "" {
+		objs = s.informer.GetIndexer().List()
+	} else {
+		var err error
+		if objs, err = s.informer.GetIndexer().ByIndex(cache.NamespaceIndex, namespace); err != nil {
+			log.Errorf("%v", err)
+			return nil
+		}
+	}
+	pods := make([]*core_v1.Pod, 0, len(objs))
+	for _, obj := range objs {
+		if pod, ok := obj.(*core_v1.Pod); ok {
+			pods = append(pods, pod)
+		}
+	}
+	return pods
+}
+
+// newPodRecord converts a pod into the record served by the query API
+func newPodRecord(pod *core_v1.Pod, state string) PodRecord {
+	return PodRecord{
+		Namespace: pod.Namespace,
+		Name:      pod.Name,
+		Phase:     pod.Status.Phase,
+		State:     state,
+		NodeName:  pod.Spec.NodeName,
+		Owner:     podOwner(pod),
+		Labels:    pod.Labels,
+	}
+}
+
+func (s *APIServer) podRecord(pod *core_v1.Pod, state string) PodRecord {
+	record := newPodRecord(pod, state)
+	if lifecycle, exists := s.tracker.Lifecycle(pod.Namespace + "/" + pod.Name); exists {
+		record.Lifecycle = &lifecycle
+	}
+	record.Containers = containerRecords(pod)
+	security := evaluatePodSecurity(pod)
+	record.Security = &security
+	return record
+}
+
+// podState is the pod phase in lower case, or stuck for pods that have been
+// pending for longer than stuckAfter
+func (s *APIServer) podState(pod *core_v1.Pod, now time.Time) string {
+	if isStuck(pod, now, s.stuckAfter) {
+		return "stuck"
+	}
+	return strings.ToLower(string(pod.Status.Phase))
+}
+
+func parsePodFilter(r *http.Request) (podFilter, error) {
+	query := r.URL.Query()
+	filter := podFilter{
+		namespace: query.Get("namespace"),
+		phase:     core_v1.PodPhase(query.Get("phase")),
+		state:     strings.ToLower(query.Get("state")),
+		selector:  labels.Everything(),
+	}
+	if label := query.Get("label"); label != "" {
+		selector, err := labels.Parse(label)
+		if err != nil {
+			return filter, fmt.Errorf("invalid label selector: %v", err)
+		}
+		filter.selector = selector
+	}
+	return filter, nil
+}
+
+func (f podFilter) matches(pod *core_v1.Pod, state string) bool {
+	if f.namespace != "" && f.namespace != pod.Namespace {
+		return false
+	}
+	if f.phase != "" && f.phase != pod.Status.Phase {
+		return false
+	}
+	if f.state != "" && f.state != state {
+		return false
+	}
+	return f.selector.Matches(labels.Set(pod.Labels))
+}
+
+func recordKey(record PodRecord) string {
+	return record.Namespace + "/" + record.Name
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Errorf("failed to write response: %v", err)
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	core_v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func newTestAPIServer(t *testing.T, pods ...core_v1.Pod) *APIServer {
+	informer := cache.NewSharedIndexInformer(&cache.ListWatch{}, &core_v1.Pod{}, 0,
+		cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	tracker := NewPodTracker(time.Time{})
+	for i := range pods {
+		require.NoError(t, informer.GetIndexer().Add(&pods[i]))
+		tracker.Observe(pods[i].Namespace+"/"+pods[i].Name, &pods[i])
+	}
+	handler := &PodHandler{tracker: tracker, options: HandlerOptions{Broker: NewEventBroker(10, 10), StuckAfter: time.Minute}}
+	return NewAPIServer(informer, handler)
+}
+
+func getJSON(t *testing.T, handler http.Handler, url string, v interface{}) {
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, url, nil))
+	require.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), v))
+}
+
+func TestAPICountsAndStuckPods(t *testing.T) {
+	now := time.Now()
+	web := newTestPod("web", "a", core_v1.PodRunning, now)
+	web.Labels = map[string]string{"app": "web"}
+	server := newTestAPIServer(t,
+		web,
+		newTestPod("web", "b", core_v1.PodPending, now.Add(-time.Hour)),
+		newTestPod("db", "c", core_v1.PodPending, now),
+	)
+
+	var counts Counts
+	getJSON(t, server, "/api/v1/counts?namespace=web", &counts)
+	require.Equal(t, Counts{PodCreatedCount: 1, PodRunningCount: 1, PodPendingCount: 1, PodStuckCount: 1}, counts)
+
+	getJSON(t, server, "/api/v1/counts?label=app%3Dweb", &counts)
+	require.Equal(t, 1, counts.PodRunningCount)
+	require.Equal(t, 0, counts.PodPendingCount)
+
+	var page PodPage
+	getJSON(t, server, "/api/v1/pods?state=stuck", &page)
+	require.Len(t, page.Items, 1)
+	require.Equal(t, "b", page.Items[0].Name)
+	require.NotNil(t, page.Items[0].Lifecycle)
+}
+
+func TestAPIPodsPagination(t *testing.T) {
+	now := time.Now()
+	server := newTestAPIServer(t,
+		newTestPod("default", "a", core_v1.PodRunning, now),
+		newTestPod("default", "b", core_v1.PodRunning, now),
+		newTestPod("default", "c", core_v1.PodRunning, now),
+	)
+
+	var page PodPage
+	getJSON(t, server, "/api/v1/pods?limit=2", &page)
+	require.Len(t, page.Items, 2)
+	require.Equal(t, "default/b", page.Continue)
+
+	var next PodPage
+	getJSON(t, server, "/api/v1/pods?limit=2&continue="+page.Continue, &next)
+	require.Len(t, next.Items, 1)
+	require.Equal(t, "c", next.Items[0].Name)
+	require.Empty(t, next.Continue)
+}
+
+func TestAPIImages(t *testing.T) {
+	now := time.Now()
+	web := newTestPod("web", "a", core_v1.PodRunning, now)
+	web.Spec.Containers = []core_v1.Container{{Name: "nginx", Image: "nginx:1.17"}, {Name: "proxy", Image: "quay.io/envoy/envoy:v1"}}
+	web.Status.ContainerStatuses = []core_v1.ContainerStatus{{Name: "nginx", ImageID: "docker-pullable://nginx@sha256:abc"}}
+	db := newTestPod("db", "b", core_v1.PodRunning, now)
+	db.Spec.Containers = []core_v1.Container{{Name: "nginx", Image: "docker.io/library/nginx:1.17"}}
+	server := newTestAPIServer(t, web, db)
+
+	var inventory ImageInventory
+	getJSON(t, server, "/api/v1/images", &inventory)
+	require.Len(t, inventory.Images, 2)
+	require.Equal(t, ImageRecord{
+		Image:      "docker.io/library/nginx:1.17",
+		Registry:   "docker.io",
+		Repository: "library/nginx",
+		Tag:        "1.17",
+		Digests:    []string{"sha256:abc"},
+		Containers: 2,
+		Pods:       2,
+		Namespaces: map[string]int32{"db": 1, "web": 1},
+	}, inventory.Images[0])
+	require.Equal(t, []RegistryCount{{Registry: "docker.io", Images: 1, Containers: 2}, {Registry: "quay.io", Images: 1, Containers: 1}}, inventory.Registries)
+
+	recorder := httptest.NewRecorder()
+	server.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/v1/images?registry=quay.io&format=csv", nil))
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.Equal(t, "image,registry,repository,tag,digests,namespace,containers\nquay.io/envoy/envoy:v1,quay.io,envoy/envoy,v1,,web,1\n", recorder.Body.String())
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jayapriya90/k8s-pod-monitor/v1alpha1"
+	core_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	// churnInterval is the interval pod creations are counted over
+	churnInterval = time.Minute
+	// churnAlpha is the weight of the latest interval in the EWMA baseline
+	churnAlpha = 0.1
+	// churnWarmup is the number of intervals a baseline needs before spikes are flagged
+	churnWarmup = 10
+)
+
+// churnBaseline is the EWMA of the creations per interval of an owner and of
+// their variance
+type churnBaseline struct {
+	mean, variance float64
+	intervals      int
+	// pending counts the creations of the interval in progress
+	pending int
+	// reference is the object events about the owner are emitted on
+	reference core_v1.ObjectReference
+	// since is set while the owner is anomalous
+	since *time.Time
+}
+
+// churnAlert is an anomaly that started in the last closed interval
+type churnAlert struct {
+	reference core_v1.ObjectReference
+	anomaly   v1alpha1.ChurnAnomaly
+}
+
+// ChurnDetector keeps an EWMA baseline of pod creations per namespace and
+// owner and flags intervals with creations beyond deviation standard deviations
+type ChurnDetector struct {
+	mu           sync.Mutex
+	deviation    float64
+	minCreations int
+	// jobs resolves the CronJob of Job pods when set, every run is a new Job
+	jobs      cache.Store
+	baselines map[string]*churnBaseline
+	// interval is the start of the interval in progress
+	interval time.Time
+	// anomalies are the anomalies of the last closed interval
+	anomalies []v1alpha1.ChurnAnomaly
+}
+
+// NewChurnDetector returns a detector flagging intervals with at least
+// minCreations creations and more than deviation standard deviations above the
+// baseline. Pods of Jobs are counted towards their CronJob found in jobs
+func NewChurnDetector(deviation float64, minCreations int, jobs cache.Store) *ChurnDetector {
+	return &ChurnDetector{deviation: deviation, minCreations: minCreations, jobs: jobs, baselines: make(map[string]*churnBaseline)}
+}
+
+// churnOwner returns the owner pod creations are counted towards as
+// `Kind/name` and the object events about it are emitted on: the controller of
+// the pod, the CronJob of a Job, or the pod itself without controller
+func (d *ChurnDetector) churnOwner(pod *core_v1.Pod) (string, core_v1.ObjectReference) {
+	ref := meta_v1.GetControllerOf(pod)
+	if ref == nil {
+		return noOwner, podReference(pod)
+	}
+	if ref.Kind == "Job" && d.jobs != nil {
+		if obj, exists, err := d.jobs.GetByKey(pod.Namespace + "/" + ref.Name); err == nil && exists {
+			if cronJob := meta_v1.GetControllerOf(obj.(meta_v1.Object)); cronJob != nil {
+				ref = cronJob
+			}
+		}
+	}
+	return ref.Kind + "/" + ref.Name, core_v1.ObjectReference{Kind: ref.Kind, APIVersion: ref.APIVersion, Namespace: pod.Namespace, Name: ref.Name, UID: ref.UID}
+}
+
+// ObserveCreation counts the creation of a pod towards its owner
+func (d *ChurnDetector) ObserveCreation(pod *core_v1.Pod) {
+	owner, reference := d.churnOwner(pod)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	key := pod.Namespace + "/" + owner
+	baseline, exists := d.baselines[key]
+	if !exists {
+		baseline = &churnBaseline{}
+		d.baselines[key] = baseline
+	}
+	baseline.pending++
+	baseline.reference = reference
+}
+
+// close ends an interval: every owner is compared with its baseline, which
+// then takes the creations of the interval in
+func (d *ChurnDetector) close(end time.Time) []churnAlert {
+	var alerts []churnAlert
+	d.anomalies = nil
+	for key, baseline := range d.baselines {
+		creations := float64(baseline.pending)
+		threshold := baseline.mean + d.deviation*math.Sqrt(baseline.variance)
+		if baseline.intervals >= churnWarmup && baseline.pending >= d.minCreations && creations > threshold {
+			if baseline.since == nil {
+				since := end
+				baseline.since = &since
+			}
+			i := strings.Index(key, "/")
+			anomaly := v1alpha1.ChurnAnomaly{
+				Namespace: key[:i],
+				Owner:     key[i+1:],
+				Creations: int32(baseline.pending),
+				Baseline:  fmt.Sprintf("%.1f", baseline.mean),
+				Since:     meta_v1.NewTime(*baseline.since),
+			}
+			d.anomalies = append(d.anomalies, anomaly)
+			if baseline.since.Equal(end) {
+				alerts = append(alerts, churnAlert{reference: baseline.reference, anomaly: anomaly})
+			}
+		} else {
+			baseline.since = nil
+		}
+		diff := creations - baseline.mean
+		baseline.mean += churnAlpha * diff
+		baseline.variance = (1 - churnAlpha) * (baseline.variance + churnAlpha*diff*diff)
+		baseline.intervals++
+		baseline.pending = 0
+		// owners that stopped creating pods are forgotten once their baseline has decayed
+		if baseline.intervals > churnWarmup && baseline.mean < 0.01 && baseline.since == nil {
+			delete(d.baselines, key)
+		}
+	}
+	sort.Slice(d.anomalies, func(i, j int) bool {
+		a, b := d.anomalies[i], d.anomalies[j]
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return a.Owner < b.Owner
+	})
+	return alerts
+}
+
+// Evaluate closes the intervals that ended before now and returns the
+// anomalies of the last closed interval, and the ones that started in it
+func (d *ChurnDetector) Evaluate(now time.Time) ([]v1alpha1.ChurnAnomaly, []churnAlert) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	start := now.Truncate(churnInterval)
+	if d.interval.IsZero() {
+		d.interval = start
+	}
+	var alerts []churnAlert
+	for d.interval.Before(start) {
+		d.interval = d.interval.Add(churnInterval)
+		alerts = append(alerts, d.close(d.interval)...)
+		// after a long pause the intervals without creations only decay the baselines
+		if start.Sub(d.interval) > churnWarmup*churnInterval {
+			d.interval = start.Add(-churnWarmup * churnInterval)
+		}
+	}
+	return append([]v1alpha1.ChurnAnomaly{}, d.anomalies...), alerts
+}
+
+// churnCondition reports the owners creating pods far above their baseline
+func churnCondition(anomalies []v1alpha1.ChurnAnomaly) v1alpha1.PodMonitorCondition {
+	if len(anomalies) == 0 {
+		return v1alpha1.PodMonitorCondition{Type: conditionPodChurn, Status: meta_v1.ConditionFalse, Reason: "CreationRateNormal"}
+	}
+	owners := make([]string, 0, len(anomalies))
+	for _, anomaly := range anomalies {
+		owners = append(owners, fmt.Sprintf("%s/%s (%d, baseline %s)", anomaly.Namespace, anomaly.Owner, anomaly.Creations, anomaly.Baseline))
+	}
+	return v1alpha1.PodMonitorCondition{
+		Type:    conditionPodChurn,
+		Status:  meta_v1.ConditionTrue,
+		Reason:  "CreationRateSpike",
+		Message: fmt.Sprintf("%d owners create pods far above their baseline per minute: %s", len(anomalies), summarizeKeys(owners, 5)),
+	}
+}
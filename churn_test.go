@@ -0,0 +1,52 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	batch_v1 "k8s.io/api/batch/v1"
+	core_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestChurnDetector(t *testing.T) {
+	start := time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC)
+	jobs := cache.NewStore(cache.MetaNamespaceKeyFunc)
+	controller := true
+	require.NoError(t, jobs.Add(&batch_v1.Job{ObjectMeta: meta_v1.ObjectMeta{
+		Namespace:       "batch",
+		Name:            "report-1",
+		OwnerReferences: []meta_v1.OwnerReference{{Kind: "CronJob", Name: "report", Controller: &controller}},
+	}}))
+	pod := newTestPod("batch", "report-1-x", core_v1.PodPending, start)
+	pod.OwnerReferences = []meta_v1.OwnerReference{{Kind: "Job", Name: "report-1", Controller: &controller}}
+	detector := NewChurnDetector(3, 5, jobs)
+	detector.Evaluate(start)
+
+	// one creation a minute builds the baseline
+	now := start
+	for i := 0; i < churnWarmup; i++ {
+		detector.ObserveCreation(&pod)
+		now = now.Add(churnInterval)
+		anomalies, alerts := detector.Evaluate(now)
+		require.Empty(t, anomalies)
+		require.Empty(t, alerts)
+	}
+	for i := 0; i < 20; i++ {
+		detector.ObserveCreation(&pod)
+	}
+	now = now.Add(churnInterval)
+	anomalies, alerts := detector.Evaluate(now)
+	require.Len(t, anomalies, 1)
+	require.Equal(t, "CronJob/report", anomalies[0].Owner)
+	require.Equal(t, int32(20), anomalies[0].Creations)
+	require.Len(t, alerts, 1)
+	require.Equal(t, "report", alerts[0].reference.Name)
+	require.Equal(t, meta_v1.ConditionTrue, churnCondition(anomalies).Status)
+
+	// the spike ends with the next quiet minute
+	anomalies, _ = detector.Evaluate(now.Add(churnInterval))
+	require.Empty(t, anomalies)
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jayapriya90/k8s-pod-monitor/v1alpha1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// conditionPodsStuck is raised while pods have been pending for longer than the stuck threshold
+	conditionPodsStuck = "PodsStuck"
+	// conditionNodesNearPodLimit is raised while nodes run close to their allocatable pods
+	conditionNodesNearPodLimit = "NodesNearPodLimit"
+	// conditionReplicasConcentrated is raised while all replicas of an owner share a node or zone
+	conditionReplicasConcentrated = "ReplicasConcentrated"
+	// conditionCronJobsFailing is raised while CronJobs fail or miss their scheduled runs
+	conditionCronJobsFailing = "CronJobsFailing"
+	// conditionRolloutsDegraded is raised while rollouts are stalled or their new revision crashes
+	conditionRolloutsDegraded = "RolloutsDegraded"
+	// conditionUnownedPods is raised while pods the spec alerts on are naked or orphaned
+	conditionUnownedPods = "UnownedPods"
+	// conditionQuotaExhaustion is raised while quotas are exhausted or forecast to run out within the horizon
+	conditionQuotaExhaustion = "QuotaExhaustion"
+	// conditionPodChurn is raised while owners create pods far above their baseline rate
+	conditionPodChurn = "PodChurn"
+)
+
+// setCondition adds or replaces the condition of the same type. The last
+// transition time from previous is kept as long as the status does not change
+func setCondition(conditions []v1alpha1.PodMonitorCondition, previous []v1alpha1.PodMonitorCondition, condition v1alpha1.PodMonitorCondition) []v1alpha1.PodMonitorCondition {
+	condition.LastTransitionTime = meta_v1.Now()
+	for _, existing := range previous {
+		if existing.Type == condition.Type && existing.Status == condition.Status {
+			condition.LastTransitionTime = existing.LastTransitionTime
+		}
+	}
+	for i := range conditions {
+		if conditions[i].Type == condition.Type {
+			conditions[i] = condition
+			return conditions
+		}
+	}
+	return append(conditions, condition)
+}
+
+// activeConditions returns the conditions with status True
+func activeConditions(conditions []v1alpha1.PodMonitorCondition) []v1alpha1.PodMonitorCondition {
+	active := []v1alpha1.PodMonitorCondition{}
+	for _, condition := range conditions {
+		if condition.Status == meta_v1.ConditionTrue {
+			active = append(active, condition)
+		}
+	}
+	return active
+}
+
+// stuckCondition reports the pods that have been pending for longer than stuckAfter
+func stuckCondition(stuck []string, stuckAfter time.Duration) v1alpha1.PodMonitorCondition {
+	if len(stuck) == 0 {
+		return v1alpha1.PodMonitorCondition{Type: conditionPodsStuck, Status: meta_v1.ConditionFalse, Reason: "NoStuckPods"}
+	}
+	sort.Strings(stuck)
+	return v1alpha1.PodMonitorCondition{
+		Type:    conditionPodsStuck,
+		Status:  meta_v1.ConditionTrue,
+		Reason:  "PodsPending",
+		Message: fmt.Sprintf("%d pods pending for more than %s: %s", len(stuck), stuckAfter, summarizeKeys(stuck, 5)),
+	}
+}
+
+// nodesNearPodLimitCondition reports the nodes whose running pods reach threshold of their allocatable pods
+func nodesNearPodLimitCondition(nodes *v1alpha1.NodesStatus, threshold float64) v1alpha1.PodMonitorCondition {
+	var near []string
+	for _, node := range nodes.Nodes {
+		if node.NearPodLimit {
+			near = append(near, node.Name)
+		}
+	}
+	if len(near) == 0 {
+		return v1alpha1.PodMonitorCondition{Type: conditionNodesNearPodLimit, Status: meta_v1.ConditionFalse, Reason: "NodesHavePodCapacity"}
+	}
+	return v1alpha1.PodMonitorCondition{
+		Type:    conditionNodesNearPodLimit,
+		Status:  meta_v1.ConditionTrue,
+		Reason:  "NodesNearPodLimit",
+		Message: fmt.Sprintf("%d nodes run at least %.0f%% of their allocatable pods: %s", len(near), threshold*100, summarizeKeys(near, 5)),
+	}
+}
+
+// replicasConcentratedCondition reports the owners whose replicas all run on one node or in one zone
+func replicasConcentratedCondition(topology *v1alpha1.TopologyStatus) v1alpha1.PodMonitorCondition {
+	var concentrated []string
+	for _, owner := range topology.Owners {
+		if owner.SingleNode || owner.SingleZone {
+			concentrated = append(concentrated, owner.Owner)
+		}
+	}
+	if len(concentrated) == 0 {
+		return v1alpha1.PodMonitorCondition{Type: conditionReplicasConcentrated, Status: meta_v1.ConditionFalse, Reason: "ReplicasSpread"}
+	}
+	return v1alpha1.PodMonitorCondition{
+		Type:    conditionReplicasConcentrated,
+		Status:  meta_v1.ConditionTrue,
+		Reason:  "SingleFailureDomain",
+		Message: fmt.Sprintf("%d owners run all replicas on one node or in one zone: %s", len(concentrated), summarizeKeys(concentrated, 5)),
+	}
+}
+
+// summarizeKeys joins the first max keys and notes how many were left out
+func summarizeKeys(keys []string, max int) string {
+	if len(keys) <= max {
+		return strings.Join(keys, ", ")
+	}
+	return fmt.Sprintf("%s and %d more", strings.Join(keys[:max], ", "), len(keys)-max)
+}
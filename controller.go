@@ -12,6 +12,13 @@ import (
 	"k8s.io/client-go/util/workqueue"
 )
 
+// statusRefreshPeriod is how often the handler re-evaluates time based status
+// such as stuck pods when no pod events arrive
+const statusRefreshPeriod = 30 * time.Second
+
+// usagePersistPeriod is how often the accounted usage is saved
+const usagePersistPeriod = time.Minute
+
 // Controller struct encapsulates logging, client set, informer,
 // worker queue, and handlers
 type Controller struct {
@@ -19,6 +26,9 @@ type Controller struct {
 	clientset kubernetes.Interface
 	queue     workqueue.RateLimitingInterface
 	informer  cache.SharedIndexInformer
+	// secondary informers feed the handler with the state of other resources,
+	// the handler checks their sync before reporting on them
+	secondary []cache.SharedIndexInformer
 	handler   *PodHandler
 }
 
@@ -33,6 +43,9 @@ func (c *Controller) Run(stopCh <-chan struct{}) {
 	c.logger.Info("Initiating controller")
 	// run the informer in the background to start watching pod resources
 	go c.informer.Run(stopCh)
+	for _, informer := range c.secondary {
+		go informer.Run(stopCh)
+	}
 
 	// perform initial synchronization to populate resources
 	// wait for the cache to be synced before starting workers
@@ -42,6 +55,13 @@ func (c *Controller) Run(stopCh <-chan struct{}) {
 	}
 	c.logger.Info("Cache sync completed successfully")
 
+	// periodically refresh the status for changes that are not driven by pod events
+	go wait.Until(c.handler.Refresh, statusRefreshPeriod, stopCh)
+	go wait.Until(c.handler.PersistUsage, usagePersistPeriod, stopCh)
+	if recorder := c.handler.options.Recorder; recorder != nil {
+		go recorder.Run(stopCh)
+	}
+
 	// run the runWorker method every second with a stop channel
 	wait.Until(c.runWorker, time.Second, stopCh)
 }
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronMacros are the predefined schedules the CronJob controller accepts
+var cronMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+var cronMonthNames = map[string]int{
+	"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+	"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+}
+
+var cronDayNames = map[string]int{"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6}
+
+// cronSchedule is a parsed five field cron schedule. Every field is a bitset
+// of the values it matches
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	// when both day fields are restricted a day matches either of them
+	domStar, dowStar bool
+}
+
+// parseCronSchedule parses a standard cron schedule or one of the @ macros
+func parseCronSchedule(spec string) (*cronSchedule, error) {
+	if macro, exists := cronMacros[strings.TrimSpace(spec)]; exists {
+		spec = macro
+	}
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("expected 5 fields in schedule %q", spec)
+	}
+	schedule := &cronSchedule{domStar: fields[2] == "*" || fields[2] == "?", dowStar: fields[4] == "*" || fields[4] == "?"}
+	var err error
+	for _, field := range []struct {
+		bits     *uint64
+		spec     string
+		min, max int
+		names    map[string]int
+	}{
+		{&schedule.minute, fields[0], 0, 59, nil},
+		{&schedule.hour, fields[1], 0, 23, nil},
+		{&schedule.dom, fields[2], 1, 31, nil},
+		{&schedule.month, fields[3], 1, 12, cronMonthNames},
+		{&schedule.dow, fields[4], 0, 7, cronDayNames},
+	} {
+		if *field.bits, err = parseCronField(field.spec, field.min, field.max, field.names); err != nil {
+			return nil, fmt.Errorf("schedule %q: %v", spec, err)
+		}
+	}
+	// 7 is another name for Sunday
+	if schedule.dow&(1<<7) != 0 {
+		schedule.dow |= 1
+	}
+	return schedule, nil
+}
+
+// parseCronField parses a comma separated list of values, ranges and steps
+func parseCronField(field string, min, max int, names map[string]int) (uint64, error) {
+	value := func(s string) (int, error) {
+		if n, exists := names[strings.ToLower(s)]; exists {
+			return n, nil
+		}
+		n, err := strconv.Atoi(s)
+		if err != nil || n < min || n > max {
+			return 0, fmt.Errorf("invalid value %q", s)
+		}
+		return n, nil
+	}
+
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			var err error
+			if step, err = strconv.Atoi(part[i+1:]); err != nil || step < 1 {
+				return 0, fmt.Errorf("invalid step in %q", part)
+			}
+			part = part[:i]
+		}
+		start, end := min, max
+		switch {
+		case part == "*" || part == "?":
+		case strings.Contains(part, "-"):
+			bounds := strings.SplitN(part, "-", 2)
+			var err error
+			if start, err = value(bounds[0]); err != nil {
+				return 0, err
+			}
+			if end, err = value(bounds[1]); err != nil {
+				return 0, err
+			}
+		default:
+			var err error
+			if start, err = value(part); err != nil {
+				return 0, err
+			}
+			// a single value with a step runs to the end of the range
+			if step == 1 {
+				end = start
+			}
+		}
+		if start > end {
+			return 0, fmt.Errorf("invalid range %q", part)
+		}
+		for n := start; n <= end; n += step {
+			bits |= 1 << uint(n)
+		}
+	}
+	return bits, nil
+}
+
+// matches reports whether the schedule fires at the minute of t
+func (s *cronSchedule) matches(t time.Time) bool {
+	has := func(bits uint64, n int) bool {
+		return bits&(1<<uint(n)) != 0
+	}
+	if !has(s.minute, t.Minute()) || !has(s.hour, t.Hour()) || !has(s.month, int(t.Month())) {
+		return false
+	}
+	dom, dow := has(s.dom, t.Day()), has(s.dow, int(t.Weekday()))
+	if s.domStar || s.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// next returns the first time after after, and not after until, the schedule fires
+func (s *cronSchedule) next(after, until time.Time) (time.Time, bool) {
+	for t := after.Truncate(time.Minute).Add(time.Minute); !t.After(until); t = t.Add(time.Minute) {
+		if s.matches(t) {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"io"
+	"net/http"
+)
+
+// handleDashboard serves the embedded dashboard on /. All of its data comes
+// from the query API and the event stream of the same server
+func (s *APIServer) handleDashboard(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	io.WriteString(w, dashboardHTML)
+}
+
+// dashboardHTML is kept in the binary so a port-forward to the controller is
+// all that is needed to get a live view
+const dashboardHTML = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>k8s-pod-monitor</title>
+<style>
+  body { font-family: sans-serif; margin: 2em; color: #222; }
+  h1 { font-size: 1.4em; }
+  h2 { font-size: 1.1em; margin-top: 2em; }
+  .cards { display: flex; gap: 1em; }
+  .card { border: 1px solid #ccc; border-radius: 4px; padding: 0.8em 1.2em; min-width: 7em; }
+  .card .value { font-size: 1.8em; font-weight: bold; }
+  table { border-collapse: collapse; }
+  th, td { border-bottom: 1px solid #ddd; padding: 0.3em 1em 0.3em 0; text-align: left; }
+  td.num { text-align: right; }
+  .alert { background: #fdecea; border-left: 4px solid #d93025; padding: 0.5em 1em; margin: 0.5em 0; }
+  .muted { color: #888; }
+  svg { border: 1px solid #ddd; }
+</style>
+</head>
+<body>
+<h1>PodMonitor <span id="name">pod-monitor</span> <span id="live" class="muted"></span></h1>
+<div class="cards">
+  <div class="card"><div>Created</div><div class="value" id="created">-</div></div>
+  <div class="card"><div>Running</div><div class="value" id="running">-</div></div>
+  <div class="card"><div>Pending</div><div class="value" id="pending">-</div></div>
+  <div class="card"><div>Failed</div><div class="value" id="failed">-</div></div>
+  <div class="card"><div>Stuck</div><div class="value" id="stuck">-</div></div>
+</div>
+
+<h2>Active alerts</h2>
+<div id="alerts"></div>
+
+<h2>Pods created per minute</h2>
+<svg id="chart" width="720" height="180"></svg>
+
+<h2>Namespaces</h2>
+<table>
+  <thead><tr><th>Namespace</th><th>Created</th><th>Running</th><th>Pending</th><th>Failed</th></tr></thead>
+  <tbody id="namespaces"></tbody>
+</table>
+
+<h2>Stuck pods</h2>
+<table>
+  <thead><tr><th>Namespace</th><th>Name</th><th>Owner</th><th>Node</th><th>Created</th></tr></thead>
+  <tbody id="stuckPods"></tbody>
+</table>
+
+<script>
+var samples = [];
+
+function text(value) {
+  var span = document.createElement("span");
+  span.textContent = value === undefined || value === null ? "" : value;
+  return span.innerHTML;
+}
+
+function row(cells) {
+  return "<tr>" + cells.map(function (cell) {
+    return typeof cell === "number" ? "<td class=\"num\">" + cell + "</td>" : "<td>" + text(cell) + "</td>";
+  }).join("") + "</tr>";
+}
+
+function getJSON(url) {
+  return fetch(url).then(function (response) { return response.json(); });
+}
+
+function renderCounts(counts) {
+  document.getElementById("created").textContent = counts.podCreatedCount;
+  document.getElementById("running").textContent = counts.podRunningCount;
+  document.getElementById("pending").textContent = counts.podPendingCount;
+  document.getElementById("failed").textContent = counts.podFailedCount;
+  document.getElementById("stuck").textContent = counts.podStuckCount;
+}
+
+function renderHistory(history) {
+  samples = history.buckets.map(function (bucket) {
+    return { time: Date.parse(bucket.start), created: bucket.created };
+  });
+  renderChart();
+}
+
+function renderChart() {
+  var svg = document.getElementById("chart");
+  var width = svg.getAttribute("width"), height = svg.getAttribute("height");
+  if (samples.length < 2) {
+    svg.innerHTML = "<text x=\"10\" y=\"20\" class=\"muted\">collecting samples...</text>";
+    return;
+  }
+  var first = samples[0], last = samples[samples.length - 1];
+  var min = 0, max = 0;
+  samples.forEach(function (s) { min = Math.min(min, s.created); max = Math.max(max, s.created); });
+  var span = Math.max(last.time - first.time, 1), range = Math.max(max - min, 1);
+  var points = samples.map(function (s) {
+    var x = 40 + (s.time - first.time) / span * (width - 50);
+    var y = height - 20 - (s.created - min) / range * (height - 40);
+    return x.toFixed(1) + "," + y.toFixed(1);
+  }).join(" ");
+  svg.innerHTML =
+    "<text x=\"2\" y=\"20\" font-size=\"11\">" + max + "</text>" +
+    "<text x=\"2\" y=\"" + (height - 20) + "\" font-size=\"11\">" + min + "</text>" +
+    "<text x=\"40\" y=\"" + (height - 4) + "\" font-size=\"11\">" + new Date(first.time).toLocaleTimeString() + "</text>" +
+    "<text x=\"" + (width - 70) + "\" y=\"" + (height - 4) + "\" font-size=\"11\">" + new Date(last.time).toLocaleTimeString() + "</text>" +
+    "<polyline fill=\"none\" stroke=\"#1a73e8\" stroke-width=\"2\" points=\"" + points + "\"/>";
+}
+
+function refresh() {
+  getJSON("api/v1/counts").then(renderCounts);
+  getJSON("api/v1/history?resolution=minute&window=3h").then(renderHistory, function () {});
+  getJSON("api/v1/status").then(function (status) {
+    document.getElementById("namespaces").innerHTML = (status.namespaces || []).map(function (ns) {
+      return row([ns.namespace, ns.podCreatedCount || 0, ns.podRunningCount || 0, ns.podPendingCount || 0, ns.podFailedCount || 0]);
+    }).join("");
+  });
+  getJSON("api/v1/alerts").then(function (alerts) {
+    document.getElementById("alerts").innerHTML = alerts.length === 0 ? "<span class=\"muted\">none</span>" :
+      alerts.map(function (alert) {
+        return "<div class=\"alert\"><b>" + text(alert.type) + "</b> " + text(alert.reason) + ": " + text(alert.message) +
+          " <span class=\"muted\">since " + text(alert.lastTransitionTime) + "</span></div>";
+      }).join("");
+  });
+  getJSON("api/v1/pods?state=stuck&limit=100").then(function (page) {
+    document.getElementById("stuckPods").innerHTML = page.items.map(function (pod) {
+      return row([pod.namespace, pod.name, pod.owner, pod.nodeName, pod.lifecycle ? pod.lifecycle.created : ""]);
+    }).join("");
+  });
+}
+
+var refreshedAt = 0, refreshTimer = null;
+function refreshSoon() {
+  if (refreshTimer !== null) {
+    return;
+  }
+  var wait = Math.max(refreshedAt + 1000 - Date.now(), 0);
+  refreshTimer = setTimeout(function () {
+    refreshTimer = null;
+    refreshedAt = Date.now();
+    refresh();
+  }, wait);
+}
+
+refresh();
+refreshedAt = Date.now();
+setInterval(refresh, 30000);
+
+if (window.EventSource) {
+  var stream = new EventSource("api/v1/stream");
+  stream.onopen = function () { document.getElementById("live").textContent = "(live)"; };
+  stream.onerror = function () { document.getElementById("live").textContent = "(reconnecting)"; };
+  stream.addEventListener("counts", refreshSoon);
+}
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"sort"
+	"time"
+
+	"github.com/jayapriya90/k8s-pod-monitor/v1alpha1"
+	core_v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// maxPodEvents is the number of recent events kept per pod
+const maxPodEvents = 10
+
+// PodEvent is an event recorded for a pod
+type PodEvent struct {
+	Type     string    `json:"type"`
+	Reason   string    `json:"reason"`
+	Message  string    `json:"message,omitempty"`
+	Count    int32     `json:"count"`
+	LastSeen time.Time `json:"lastSeen"`
+	uid      string
+}
+
+type eventReasonKey struct {
+	namespace string
+	eventType string
+	reason    string
+}
+
+// eventOccurrences returns how often an event happened, for both the
+// deprecated count field and event series
+func eventOccurrences(event *core_v1.Event) int32 {
+	count := event.Count
+	if event.Series != nil {
+		count = event.Series.Count
+	}
+	if count < 1 {
+		count = 1
+	}
+	return count
+}
+
+// eventLastSeen returns when an event last happened
+func eventLastSeen(event *core_v1.Event) time.Time {
+	switch {
+	case event.Series != nil:
+		return event.Series.LastObservedTime.Time
+	case !event.LastTimestamp.IsZero():
+		return event.LastTimestamp.Time
+	case !event.EventTime.IsZero():
+		return event.EventTime.Time
+	}
+	return event.CreationTimestamp.Time
+}
+
+// ObserveEvent correlates an event with the tracked pod it involves, by UID.
+// Occurrences are counted once, also when the event is updated with a higher
+// count. It returns false for events of pods that are not tracked
+func (t *PodTracker) ObserveEvent(event *core_v1.Event) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := event.InvolvedObject.Namespace + "/" + event.InvolvedObject.Name
+	pod, tracked := t.pods[key]
+	if !tracked || pod.UID != event.InvolvedObject.UID {
+		return false
+	}
+
+	count := eventOccurrences(event)
+	if delta := count - t.eventCounts[string(event.UID)]; delta > 0 {
+		t.eventReasons[eventReasonKey{namespace: pod.Namespace, eventType: event.Type, reason: event.Reason}] += delta
+	}
+	t.eventCounts[string(event.UID)] = count
+
+	lifecycle := t.lifecycles[key]
+	recent := PodEvent{
+		Type:     event.Type,
+		Reason:   event.Reason,
+		Message:  event.Message,
+		Count:    count,
+		LastSeen: eventLastSeen(event),
+		uid:      string(event.UID),
+	}
+	events := lifecycle.Events[:0]
+	for _, existing := range lifecycle.Events {
+		if existing.uid != recent.uid {
+			events = append(events, existing)
+		}
+	}
+	events = append(events, recent)
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].LastSeen.Before(events[j].LastSeen)
+	})
+	if len(events) > maxPodEvents {
+		events = events[len(events)-maxPodEvents:]
+	}
+	lifecycle.Events = events
+	return true
+}
+
+// ForgetEvent drops the bookkeeping of an event removed from the API server
+func (t *PodTracker) ForgetEvent(event *core_v1.Event) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.eventCounts, string(event.UID))
+}
+
+// eventStatus builds the per reason event counts, in total and per namespace
+func (t *PodTracker) eventStatus() *v1alpha1.EventStatus {
+	totals := make(map[eventReasonKey]int32)
+	namespaces := make(map[string][]v1alpha1.EventReason)
+	for key, count := range t.eventReasons {
+		totals[eventReasonKey{eventType: key.eventType, reason: key.reason}] += count
+		namespaces[key.namespace] = append(namespaces[key.namespace], v1alpha1.EventReason{Type: key.eventType, Reason: key.reason, Count: count})
+	}
+
+	status := &v1alpha1.EventStatus{}
+	for key, count := range totals {
+		status.Reasons = append(status.Reasons, v1alpha1.EventReason{Type: key.eventType, Reason: key.reason, Count: count})
+	}
+	sortEventReasons(status.Reasons)
+	for namespace, reasons := range namespaces {
+		sortEventReasons(reasons)
+		status.Namespaces = append(status.Namespaces, v1alpha1.NamespaceEvents{Namespace: namespace, Reasons: reasons})
+	}
+	sort.Slice(status.Namespaces, func(i, j int) bool {
+		return status.Namespaces[i].Namespace < status.Namespaces[j].Namespace
+	})
+	return status
+}
+
+func sortEventReasons(reasons []v1alpha1.EventReason) {
+	sort.Slice(reasons, func(i, j int) bool {
+		if reasons[i].Type != reasons[j].Type {
+			return reasons[i].Type < reasons[j].Type
+		}
+		return reasons[i].Reason < reasons[j].Reason
+	})
+}
+
+// EventObserved is called when an event involving a pod is added or updated.
+// Counts reach the pod-monitor status with the next refresh
+func (t *PodHandler) EventObserved(obj interface{}) {
+	if event, ok := obj.(*core_v1.Event); ok {
+		t.tracker.ObserveEvent(event)
+	}
+}
+
+// replayEvents correlates the cached events of a newly tracked pod, which were
+// dropped when they arrived before the pod
+func (t *PodHandler) replayEvents(pod *core_v1.Pod) {
+	if t.options.Events == nil {
+		return
+	}
+	objs, err := t.options.Events.ByIndex(eventPodUIDIndex, string(pod.UID))
+	if err != nil {
+		return
+	}
+	for _, obj := range objs {
+		if event, ok := obj.(*core_v1.Event); ok {
+			t.tracker.ObserveEvent(event)
+		}
+	}
+}
+
+// EventDeleted is called when an event expires or is deleted
+func (t *PodHandler) EventDeleted(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	if event, ok := obj.(*core_v1.Event); ok {
+		t.tracker.ForgetEvent(event)
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jayapriya90/k8s-pod-monitor/v1alpha1"
+	"github.com/stretchr/testify/require"
+	core_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestPodTrackerCorrelatesEvents(t *testing.T) {
+	tracker := NewPodTracker(time.Time{})
+	pod := newTestPod("web", "a", core_v1.PodRunning, time.Now())
+	pod.UID = "uid-a"
+	tracker.Observe("web/a", &pod)
+
+	event := &core_v1.Event{
+		ObjectMeta:     meta_v1.ObjectMeta{UID: "event-1"},
+		InvolvedObject: core_v1.ObjectReference{Kind: "Pod", Namespace: "web", Name: "a", UID: "uid-a"},
+		Type:           core_v1.EventTypeWarning,
+		Reason:         "BackOff",
+		Count:          1,
+		LastTimestamp:  meta_v1.Now(),
+	}
+	require.True(t, tracker.ObserveEvent(event))
+	// the same event repeated, only the new occurrences are counted
+	repeated := event.DeepCopy()
+	repeated.Count = 3
+	require.True(t, tracker.ObserveEvent(repeated))
+	// an event of an earlier pod with the same name
+	stale := event.DeepCopy()
+	stale.UID, stale.InvolvedObject.UID = "event-2", "uid-old"
+	require.False(t, tracker.ObserveEvent(stale))
+
+	events := tracker.Status().Events
+	require.Equal(t, []v1alpha1.EventReason{{Type: core_v1.EventTypeWarning, Reason: "BackOff", Count: 3}}, events.Reasons)
+	lifecycle, _ := tracker.Lifecycle("web/a")
+	require.Len(t, lifecycle.Events, 1)
+	require.Equal(t, int32(3), lifecycle.Events[0].Count)
+}
+
+func TestPodHandlerReplaysEventsOfNewPods(t *testing.T) {
+	events := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{eventPodUIDIndex: eventPodUIDIndexFunc})
+	handler := &PodHandler{tracker: NewPodTracker(time.Time{}), options: HandlerOptions{Events: events}}
+	pod := newTestPod("web", "a", core_v1.PodPending, time.Now())
+	pod.UID = "uid-a"
+
+	// the event arrives before the pod is tracked
+	event := &core_v1.Event{
+		ObjectMeta:     meta_v1.ObjectMeta{Namespace: "web", Name: "a.1", UID: "event-1"},
+		InvolvedObject: core_v1.ObjectReference{Kind: "Pod", Namespace: "web", Name: "a", UID: "uid-a"},
+		Type:           core_v1.EventTypeWarning,
+		Reason:         "FailedScheduling",
+		Count:          2,
+	}
+	require.NoError(t, events.Add(event))
+	handler.EventObserved(event)
+	require.Empty(t, handler.tracker.Status().Events.Reasons)
+
+	handler.tracker.Observe("web/a", &pod)
+	handler.replayEvents(&pod)
+	// replaying again does not count the occurrences twice
+	handler.replayEvents(&pod)
+	require.Equal(t, []v1alpha1.EventReason{{Type: core_v1.EventTypeWarning, Reason: "FailedScheduling", Count: 2}}, handler.tracker.Status().Events.Reasons)
+}
@@ -1,24 +1,91 @@
 package main
 
 import (
-	apiextension "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
-	"k8s.io/apimachinery/pkg/api/errors"
-	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/client-go/rest"
-	"k8s.io/client-go/util/retry"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
 	"time"
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/jayapriya90/k8s-pod-monitor/v1alpha1"
 	core_v1 "k8s.io/api/core/v1"
+	apiextension "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
+	"k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/retry"
 )
 
 // PodHandler is a sample implementation of Handler
 type PodHandler struct {
-	crdClient        *v1alpha1.PodMonitorV1Alpha1Client
-	startedTimestamp time.Time
-	podsCreated      map[string]bool
-	podsRunning      map[string]bool
+	crdClient *v1alpha1.PodMonitorV1Alpha1Client
+	tracker   *PodTracker
+	options   HandlerOptions
+	// mu serializes status updates from the worker and the periodic refresh
+	mu         sync.Mutex
+	lastStatus v1alpha1.PodMonitorStatus
+	// violations are the policy violations of the last status update
+	violations []PolicyViolation
+	// reported holds the rules each violating pod was last reported for by pod UID
+	reported map[string]string
+	// unprotected are the pods without NetworkPolicy of the last status update
+	unprotected []UnprotectedPod
+	// blocked are the pods with missing references of the last status update
+	blocked []BlockedPod
+	// pending are the events of the last status update, recorded once mu is released
+	pending []pendingEvent
+}
+
+// HandlerOptions holds the PodHandler settings that come from command line flags
+type HandlerOptions struct {
+	// Broker receives count changes and pod transitions for the event stream
+	Broker *EventBroker
+	// StuckAfter is the time after which a pending pod is reported as stuck
+	StuckAfter time.Duration
+	// Usage accounts pod-seconds and requested resource-seconds when set
+	Usage *UsageLedger
+	// UsageStore persists Usage across restarts when set
+	UsageStore usagePersister
+	// Nodes is the node cache pods are matched against when set
+	Nodes cache.Store
+	// NodePodThreshold is the fraction of allocatable pods above which a node is near its limit
+	NodePodThreshold float64
+	// Jobs records the outcome of finished Jobs when set
+	Jobs *JobTracker
+	// CronJobs is the CronJob cache checked for missed runs
+	CronJobs cache.Store
+	// Rollouts follows Deployment and StatefulSet rollouts when set
+	Rollouts *RolloutTracker
+	// Replicas compares workloads with their desired replicas when set
+	Replicas *ReplicaTracker
+	// Events is the event cache replayed for pods when they are first tracked when set
+	Events cache.Indexer
+	// Recorder emits events about pods when set
+	Recorder *EventRecorder
+	// NetworkPolicies is the NetworkPolicy cache pods are checked against when set
+	NetworkPolicies cache.Store
+	// References checks the objects waiting pods reference when set
+	References *ReferenceChecker
+	// Services and Endpoints are the caches running pods are joined with when set
+	Services, Endpoints cache.Store
+	// Owners are the controller caches by kind pods are checked to still be owned by when set
+	Owners map[string]cache.Store
+	// Quotas is the ResourceQuota cache the Forecaster compares the pods with when both are set
+	Quotas     cache.Store
+	Forecaster *QuotaForecaster
+	// Pods is the pod cache quota usage is taken from. Unlike the tracker it
+	// also holds the pods that were pending before the monitor started
+	Pods cache.Store
+	// History keeps time-bucketed pod counts when set
+	History *CountHistory
+	// Churn flags owners whose pod creations spike above their baseline when set
+	Churn *ChurnDetector
+	// Synced holds the HasSynced of the informer behind each cache. Status
+	// depending on a cache is left out until its informer has synced
+	Synced map[cache.Store]cache.InformerSynced
 }
 
 func createCRDClient(config *rest.Config) (*v1alpha1.PodMonitorV1Alpha1Client, time.Time, error) {
@@ -63,12 +130,34 @@ func createCRDClient(config *rest.Config) (*v1alpha1.PodMonitorV1Alpha1Client, t
 }
 
 // NewPodHandler initialization
-func NewPodHandler(config *rest.Config) *PodHandler {
+func NewPodHandler(config *rest.Config, options HandlerOptions) *PodHandler {
 	crdClient, startedTs, err := createCRDClient(config)
 	if err != nil {
 		panic(err)
 	}
-	return &PodHandler{crdClient: crdClient, startedTimestamp: startedTs, podsCreated: make(map[string]bool), podsRunning: make(map[string]bool)}
+	tracker := NewPodTracker(startedTs)
+	handler := &PodHandler{crdClient: crdClient, tracker: tracker, options: options, reported: make(map[string]string)}
+	if options.Usage != nil {
+		handler.loadUsage()
+		tracker.EnableUsage(options.Usage)
+	}
+	return handler
+}
+
+// loadUsage resumes the usage ledger from the usage store. When the stored
+// usage cannot be read it is not saved either, so the usage accounted from
+// now on does not overwrite it
+func (t *PodHandler) loadUsage() {
+	if t.options.UsageStore == nil {
+		return
+	}
+	snapshot, err := t.options.UsageStore.Load()
+	if err != nil {
+		log.Errorf("Failed to load usage, usage is not persisted: %v", err)
+		t.options.UsageStore = nil
+		return
+	}
+	t.options.Usage.Load(snapshot)
 }
 
 // ObjectCreated is called when an object is created
@@ -76,38 +165,292 @@ func (t *PodHandler) ObjectCreated(key string, obj interface{}) {
 	log.Infof("PodHandler.ObjectCreated -> %s", key)
 	// assert the type to a Pod object to pull out relevant data
 	pod := obj.(*core_v1.Pod)
-	if pod.Status.Phase == "Pending" {
-		if pod.CreationTimestamp.Time.Before(t.startedTimestamp) {
-			log.Infof("%s pod created before k8s pod monitor service start..ignoring", key)
-			return
-		}
-		if _, exists := t.podsCreated[key]; !exists {
-			t.podsCreated[key] = true
-		}
+	var previous core_v1.PodPhase
+	last, tracked := t.tracker.Pod(key)
+	deleted := 0
+	if tracked && last.UID != pod.UID {
+		// the pod was recreated under the same name, the tracker forgets the old one
+		t.publishTransition(last, string(last.Status.Phase), phaseDeleted)
+		tracked, deleted = false, 1
+	}
+	if tracked {
+		previous = last.Status.Phase
+	}
+	created := t.tracker.CreatedCount()
+	if !t.tracker.Observe(key, pod) {
+		log.Infof("%s pod created before k8s pod monitor service start..ignoring", key)
+		return
+	}
+	if !tracked {
+		t.replayEvents(pod)
+	}
+	if previous != pod.Status.Phase {
+		t.publishTransition(pod, string(previous), string(pod.Status.Phase))
+	}
+	if t.options.Churn != nil && t.tracker.CreatedCount() > created {
+		t.options.Churn.ObserveCreation(pod)
 	}
-	if pod.Status.Phase == "Running" {
-		if _, exists := t.podsRunning[key]; !exists {
-			t.podsRunning[key] = true
+	if t.options.Forecaster != nil && t.tracker.CreatedCount() > created {
+		t.options.Forecaster.ObserveCreation(pod, time.Now())
+	}
+	if t.options.History != nil {
+		failed := 0
+		if previous != core_v1.PodFailed && pod.Status.Phase == core_v1.PodFailed {
+			failed = 1
 		}
+		t.options.History.Record(time.Now(), t.tracker.CreatedCount()-created, deleted, failed, t.tracker.RunningCount())
 	}
-	log.Infof("    podsCreated: %d", len(t.podsCreated))
-	log.Infof("    podsRunning: %d", len(t.podsRunning))
+	log.Infof("    podsCreated: %d", t.tracker.CreatedCount())
+	log.Infof("    podsRunning: %d", t.tracker.RunningCount())
 	t.updateCRD()
 }
 
 // ObjectDeleted is called when an object is deleted
 func (t *PodHandler) ObjectDeleted(key string, obj interface{}) {
 	log.Infof("PodHandler.ObjectDeleted -> %s", key)
-	delete(t.podsRunning, key)
-	log.Infof("    podsCreated: %d", len(t.podsCreated))
-	log.Infof("    podsRunning: %d", len(t.podsRunning))
+	// the informer no longer has the object, use the last state the tracker saw
+	last, tracked := t.tracker.Pod(key)
+	if tracked {
+		t.publishTransition(last, string(last.Status.Phase), phaseDeleted)
+	}
+	t.tracker.Forget(key)
+	if tracked && t.options.History != nil {
+		t.options.History.Record(time.Now(), 0, 1, 0, t.tracker.RunningCount())
+	}
+	log.Infof("    podsCreated: %d", t.tracker.CreatedCount())
+	log.Infof("    podsRunning: %d", t.tracker.RunningCount())
+	t.updateCRD()
+}
+
+// Refresh re-evaluates the time based parts of the status, such as stuck pods,
+// which change without any pod event
+func (t *PodHandler) Refresh() {
+	if t.options.History != nil {
+		t.options.History.Record(time.Now(), 0, 0, 0, t.tracker.RunningCount())
+	}
 	t.updateCRD()
 }
 
+// PersistUsage accounts the usage of the running pods and saves it to the usage store
+func (t *PodHandler) PersistUsage() {
+	if t.options.Usage == nil || t.options.UsageStore == nil {
+		return
+	}
+	t.tracker.AccrueUsage()
+	if err := t.options.UsageStore.Save(t.options.Usage.Snapshot()); err != nil {
+		log.Errorf("Failed to save usage: %v", err)
+	}
+}
+
+// Status returns the status last written to the pod-monitor resource
+func (t *PodHandler) Status() v1alpha1.PodMonitorStatus {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return *t.lastStatus.DeepCopy()
+}
+
+// PolicyViolations returns the pods that violated the policy at the last status update
+func (t *PodHandler) PolicyViolations() []PolicyViolation {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]PolicyViolation{}, t.violations...)
+}
+
+// UnprotectedPods returns the running pods not selected by any NetworkPolicy
+// at the last status update, or nil when NetworkPolicy coverage is disabled
+func (t *PodHandler) UnprotectedPods() []UnprotectedPod {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.unprotected == nil {
+		return nil
+	}
+	return append([]UnprotectedPod{}, t.unprotected...)
+}
+
+// BlockedPods returns the waiting pods referencing missing objects at the last status update
+func (t *PodHandler) BlockedPods() []BlockedPod {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]BlockedPod{}, t.blocked...)
+}
+
+// synced reports whether the informers of the given caches have synced. Nil
+// caches and caches without informer count as synced
+func (t *PodHandler) synced(stores ...cache.Store) bool {
+	for _, store := range stores {
+		if store == nil {
+			continue
+		}
+		if hasSynced, exists := t.options.Synced[store]; exists && !hasSynced() {
+			return false
+		}
+	}
+	return true
+}
+
+// buildStatus combines the tracker counts with the conditions the handler
+// evaluates and the checks configured in spec
+func (t *PodHandler) buildStatus(spec v1alpha1.PodMonitorSpec) v1alpha1.PodMonitorStatus {
+	t.tracker.AccrueUsage()
+	pods := t.tracker.Pods()
+	status := t.tracker.Status()
+	stuck := t.tracker.StuckPods(time.Now(), t.options.StuckAfter)
+	status.Conditions = setCondition(status.Conditions, t.lastStatus.Conditions, stuckCondition(stuck, t.options.StuckAfter))
+	if t.options.Nodes != nil && t.synced(t.options.Nodes) {
+		running, nodes := t.tracker.RunningPods(), t.options.Nodes.List()
+		status.Nodes = nodesStatus(running, nodes, t.options.NodePodThreshold)
+		status.Conditions = setCondition(status.Conditions, t.lastStatus.Conditions, nodesNearPodLimitCondition(status.Nodes, t.options.NodePodThreshold))
+		status.Topology = topologyStatus(running, nodes)
+		status.Conditions = setCondition(status.Conditions, t.lastStatus.Conditions, replicasConcentratedCondition(status.Topology))
+	}
+	if t.options.Rollouts != nil && t.synced(t.options.Rollouts.replicaSets, t.options.Rollouts.statefulSets) {
+		status.Rollouts = t.options.Rollouts.Update(pods, time.Now())
+		status.Conditions = setCondition(status.Conditions, t.lastStatus.Conditions, rolloutsDegradedCondition(status.Rollouts, t.options.Rollouts.stallAfter))
+	}
+	if t.options.Replicas != nil && t.synced(t.options.Replicas.workloads...) {
+		status.Shortfalls = t.options.Replicas.Update(pods, time.Now())
+	}
+	// Jobs are recorded from the Job informer, which also caches the Job owners
+	if t.options.Jobs != nil && t.synced(t.options.Owners["Job"], t.options.CronJobs) {
+		var cronJobs []interface{}
+		if t.options.CronJobs != nil {
+			cronJobs = t.options.CronJobs.List()
+		}
+		status.Jobs = t.options.Jobs.Status(time.Now(), cronJobs)
+		status.Conditions = setCondition(status.Conditions, t.lastStatus.Conditions, cronJobsFailingCondition(status.Jobs))
+	}
+	if t.options.NetworkPolicies != nil && t.synced(t.options.NetworkPolicies) {
+		status.NetworkCoverage, t.unprotected = networkCoverage(pods, t.options.NetworkPolicies.List())
+	}
+	if t.options.References != nil && t.synced(t.options.References.caches()...) {
+		status.MissingReferences, t.blocked = t.options.References.Check(pods)
+	}
+	if t.options.Services != nil && t.options.Endpoints != nil && t.synced(t.options.Services, t.options.Endpoints) {
+		status.Services = servicesStatus(pods, t.options.Services.List(), t.options.Endpoints.List())
+	}
+	if t.options.Owners != nil && t.synced(ownerCaches(t.options.Owners)...) {
+		status.Unowned = unownedStatus(pods, t.options.Owners)
+		if spec.UnownedPods != nil {
+			status.Conditions = setCondition(status.Conditions, t.lastStatus.Conditions, unownedPodsCondition(status.Unowned, spec.UnownedPods))
+		}
+	}
+	if t.options.Quotas != nil && t.options.Forecaster != nil && t.options.Pods != nil && t.synced(t.options.Quotas) {
+		status.Quotas = t.options.Forecaster.Update(t.options.Pods.List(), t.options.Quotas.List(), time.Now())
+		status.Conditions = setCondition(status.Conditions, t.lastStatus.Conditions, quotaExhaustionCondition(status.Quotas, t.options.Forecaster.horizon))
+	}
+	if t.options.Churn != nil {
+		var alerts []churnAlert
+		status.Churn, alerts = t.options.Churn.Evaluate(time.Now())
+		status.Conditions = setCondition(status.Conditions, t.lastStatus.Conditions, churnCondition(status.Churn))
+		for _, alert := range alerts {
+			t.pending = append(t.pending, pendingEvent{
+				object:    alert.reference,
+				eventType: core_v1.EventTypeWarning,
+				reason:    "PodChurn",
+				message:   fmt.Sprintf("Created %d pods in a minute, baseline %s", alert.anomaly.Creations, alert.anomaly.Baseline),
+			})
+		}
+	}
+	if t.options.History != nil {
+		status.History = t.options.History.Summary(time.Now(), historySummaryWindow)
+	}
+	if spec.Images != nil {
+		status.Images = imagesStatus(spec.Images, pods)
+	}
+	if spec.Policy != nil {
+		t.violations = policyViolations(spec.Policy, pods)
+		status.Policy = policyStatus(t.violations)
+	} else {
+		t.violations = nil
+	}
+	t.reportViolations(pods)
+	return status
+}
+
+// reportViolations queues a PolicyViolation event for every pod whose failed
+// rules changed since it was last reported. Pods that already have the event,
+// reported before a restart, are not reported again
+func (t *PodHandler) reportViolations(pods []*core_v1.Pod) {
+	byKey := make(map[string]*core_v1.Pod, len(pods))
+	for _, pod := range pods {
+		byKey[pod.Namespace+"/"+pod.Name] = pod
+	}
+	violating := make(map[string]bool, len(t.violations))
+	for _, violation := range t.violations {
+		pod, exists := byKey[violation.Namespace+"/"+violation.Name]
+		if !exists {
+			continue
+		}
+		uid, rules := string(pod.UID), strings.Join(violation.Rules, ", ")
+		violating[uid] = true
+		if t.reported[uid] == rules {
+			continue
+		}
+		t.reported[uid] = rules
+		message := "Pod violates policy rules: " + rules
+		if t.hasEvent(pod, "PolicyViolation", message) {
+			continue
+		}
+		t.pending = append(t.pending, pendingEvent{object: podReference(pod), eventType: core_v1.EventTypeWarning, reason: "PolicyViolation", message: message})
+	}
+	for uid := range t.reported {
+		if !violating[uid] {
+			delete(t.reported, uid)
+		}
+	}
+}
+
+// hasEvent reports whether the event cache holds an event the monitor
+// emitted about pod with reason and message
+func (t *PodHandler) hasEvent(pod *core_v1.Pod, reason, message string) bool {
+	if t.options.Events == nil {
+		return false
+	}
+	objs, err := t.options.Events.ByIndex(eventPodUIDIndex, string(pod.UID))
+	if err != nil {
+		return false
+	}
+	for _, obj := range objs {
+		if event, ok := obj.(*core_v1.Event); ok && event.Source.Component == eventComponent && event.Reason == reason && event.Message == message {
+			return true
+		}
+	}
+	return false
+}
+
+// updateCRD writes the status and records the events of the update once the
+// status lock is released
 func (t *PodHandler) updateCRD() {
+	t.mu.Lock()
+	t.writeStatus()
+	pending := t.pending
+	t.pending = nil
+	t.mu.Unlock()
+
+	if t.options.Recorder == nil {
+		return
+	}
+	for _, event := range pending {
+		t.options.Recorder.Eventf(event.object, event.eventType, event.reason, "%s", event.message)
+	}
+}
+
+// writeStatus builds the status and writes it to the pod-monitor resource. mu must be held
+func (t *PodHandler) writeStatus() {
 	current, err := t.crdClient.PodMonitors("default").Get("pod-monitor")
-	current.Status.PodRunningCount = int32(len(t.podsRunning))
-	current.Status.PodCreatedCount = int32(len(t.podsCreated))
+	if err != nil {
+		log.Errorf("%v", err)
+		return
+	}
+	// the full status is served by the query API, the resource gets the truncated lists
+	status := t.buildStatus(current.Spec)
+	if statusChanged(t.lastStatus, status) {
+		t.lastStatus = status
+		if t.options.Broker != nil {
+			t.options.Broker.Publish(StreamEvent{Type: streamEventCounts})
+		}
+	}
+	current.Status = truncateStatus(status)
 	err = retry.RetryOnConflict(retry.DefaultBackoff, func() error {
 		_, err := t.crdClient.PodMonitors("default").Update(current)
 		if err != nil {
@@ -120,3 +463,27 @@ func (t *PodHandler) updateCRD() {
 		log.Errorf("%v", err)
 	}
 }
+
+// statusChanged compares statuses by their serialized form, as resource
+// quantities with the same value can have different internal representations
+func statusChanged(previous, current v1alpha1.PodMonitorStatus) bool {
+	a, errA := json.Marshal(previous)
+	b, errB := json.Marshal(current)
+	return errA != nil || errB != nil || !bytes.Equal(a, b)
+}
+
+// publishTransition sends a pod phase transition to the event stream
+func (t *PodHandler) publishTransition(pod *core_v1.Pod, from, to string) {
+	if t.options.Broker == nil {
+		return
+	}
+	t.options.Broker.Publish(StreamEvent{Type: streamEventPod, Pod: &PodTransition{
+		Namespace: pod.Namespace,
+		Name:      pod.Name,
+		Owner:     podOwner(pod),
+		Labels:    pod.Labels,
+		From:      from,
+		To:        to,
+		Time:      time.Now(),
+	}})
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	core_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestPodHandlerSyncedChecksOwnInformers(t *testing.T) {
+	nodes, quotas := cache.NewStore(cache.MetaNamespaceKeyFunc), cache.NewStore(cache.MetaNamespaceKeyFunc)
+	nodesSynced := false
+	handler := &PodHandler{options: HandlerOptions{Synced: map[cache.Store]cache.InformerSynced{
+		nodes:  func() bool { return nodesSynced },
+		quotas: func() bool { return true },
+	}}}
+
+	require.False(t, handler.synced(nodes))
+	require.True(t, handler.synced(quotas))
+	require.True(t, handler.synced(nil, cache.NewStore(cache.MetaNamespaceKeyFunc)))
+
+	nodesSynced = true
+	require.True(t, handler.synced(nodes, quotas))
+}
+
+func TestPodHandlerReportsViolationsOnce(t *testing.T) {
+	events := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{eventPodUIDIndex: eventPodUIDIndexFunc})
+	handler := &PodHandler{options: HandlerOptions{Events: events}, reported: make(map[string]string)}
+	reported, fresh := newTestPod("web", "a", core_v1.PodRunning, time.Now()), newTestPod("web", "b", core_v1.PodRunning, time.Now())
+	reported.UID, fresh.UID = "uid-a", "uid-b"
+	pods := []*core_v1.Pod{&reported, &fresh}
+	handler.violations = []PolicyViolation{{Namespace: "web", Name: "a", Rules: []string{"latestTag"}}, {Namespace: "web", Name: "b", Rules: []string{"latestTag"}}}
+
+	// the event of pod a was emitted before a restart
+	require.NoError(t, events.Add(&core_v1.Event{
+		ObjectMeta:     meta_v1.ObjectMeta{Namespace: "web", Name: "a.1"},
+		InvolvedObject: podReference(&reported),
+		Reason:         "PolicyViolation",
+		Message:        "Pod violates policy rules: latestTag",
+		Source:         core_v1.EventSource{Component: eventComponent},
+	}))
+	handler.reportViolations(pods)
+	require.Len(t, handler.pending, 1)
+	require.Equal(t, "b", handler.pending[0].object.Name)
+
+	handler.pending = nil
+	handler.reportViolations(pods)
+	require.Empty(t, handler.pending)
+}
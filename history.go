@@ -0,0 +1,174 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/jayapriya90/k8s-pod-monitor/v1alpha1"
+)
+
+const (
+	// historyMinute and historyHour are the resolutions history is kept at
+	historyMinute = "minute"
+	historyHour   = "hour"
+	// historySummaryWindow is the recent history summarized in the status
+	historySummaryWindow = time.Hour
+)
+
+// HistoryBucket counts the pod changes within one minute or hour starting at
+// Start. The running watermarks are the most and fewest pods running at once
+type HistoryBucket struct {
+	Start       time.Time `json:"start"`
+	Created     int32     `json:"created"`
+	Deleted     int32     `json:"deleted"`
+	Failed      int32     `json:"failed"`
+	RunningHigh int32     `json:"runningHigh"`
+	RunningLow  int32     `json:"runningLow"`
+}
+
+// HistoryPage is the response of /api/v1/history
+type HistoryPage struct {
+	Resolution string          `json:"resolution"`
+	Buckets    []HistoryBucket `json:"buckets"`
+}
+
+// historyRing is a fixed size ring of consecutive buckets of one resolution
+type historyRing struct {
+	resolution time.Duration
+	buckets    []HistoryBucket
+	// next is the slot the next bucket is written to, count the buckets in use
+	next, count int
+}
+
+// newHistoryRing returns a ring of size buckets, or nil when size is not positive
+func newHistoryRing(resolution time.Duration, size int) *historyRing {
+	if size <= 0 {
+		return nil
+	}
+	return &historyRing{resolution: resolution, buckets: make([]HistoryBucket, size)}
+}
+
+// current returns the most recent bucket, nil while the ring is empty
+func (r *historyRing) current() *HistoryBucket {
+	if r.count == 0 {
+		return nil
+	}
+	return &r.buckets[(r.next-1+len(r.buckets))%len(r.buckets)]
+}
+
+// push appends a bucket, overwriting the oldest one once the ring is full
+func (r *historyRing) push(bucket HistoryBucket) {
+	r.buckets[r.next] = bucket
+	r.next = (r.next + 1) % len(r.buckets)
+	if r.count < len(r.buckets) {
+		r.count++
+	}
+}
+
+// advance makes the bucket containing now the current one. Buckets skipped
+// without changes are filled with the running level of before
+func (r *historyRing) advance(now time.Time, running int32) *HistoryBucket {
+	start := now.Truncate(r.resolution)
+	current := r.current()
+	if current == nil || start.Sub(current.Start) > r.resolution*time.Duration(len(r.buckets)) {
+		r.push(HistoryBucket{Start: start, RunningHigh: running, RunningLow: running})
+		return r.current()
+	}
+	for current.Start.Before(start) {
+		r.push(HistoryBucket{Start: current.Start.Add(r.resolution), RunningHigh: running, RunningLow: running})
+		current = r.current()
+	}
+	return current
+}
+
+// since returns copies of the buckets ending after since, oldest first. A
+// disabled ring has no buckets
+func (r *historyRing) since(since time.Time) []HistoryBucket {
+	buckets := []HistoryBucket{}
+	if r == nil {
+		return buckets
+	}
+	for i := 0; i < r.count; i++ {
+		bucket := r.buckets[(r.next-r.count+i+len(r.buckets))%len(r.buckets)]
+		if bucket.Start.Add(r.resolution).After(since) {
+			buckets = append(buckets, bucket)
+		}
+	}
+	return buckets
+}
+
+// CountHistory keeps per-minute and per-hour buckets of pod changes in ring
+// buffers, so recent activity can be answered without an external TSDB
+type CountHistory struct {
+	mu      sync.Mutex
+	minutes *historyRing
+	hours   *historyRing
+	// running is the last recorded number of running pods
+	running  int32
+	recorded bool
+}
+
+// NewCountHistory returns a history keeping the given number of minute and
+// hour buckets. A resolution without buckets is not kept
+func NewCountHistory(minutes, hours int) *CountHistory {
+	return &CountHistory{
+		minutes: newHistoryRing(time.Minute, minutes),
+		hours:   newHistoryRing(time.Hour, hours),
+	}
+}
+
+// Record adds pod changes at now and the number of pods running after them
+func (h *CountHistory) Record(now time.Time, created, deleted, failed, running int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if !h.recorded {
+		// the first bucket starts at the running level of the first record
+		h.running = int32(running)
+		h.recorded = true
+	}
+	for _, ring := range []*historyRing{h.minutes, h.hours} {
+		if ring == nil {
+			continue
+		}
+		bucket := ring.advance(now, h.running)
+		bucket.Created += int32(created)
+		bucket.Deleted += int32(deleted)
+		bucket.Failed += int32(failed)
+		if int32(running) > bucket.RunningHigh {
+			bucket.RunningHigh = int32(running)
+		}
+		if int32(running) < bucket.RunningLow {
+			bucket.RunningLow = int32(running)
+		}
+	}
+	h.running = int32(running)
+}
+
+// Buckets returns the buckets of a resolution ending after since, oldest first
+func (h *CountHistory) Buckets(resolution string, since time.Time) []HistoryBucket {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if resolution == historyHour {
+		return h.hours.since(since)
+	}
+	return h.minutes.since(since)
+}
+
+// Summary sums the minute buckets of the window before now
+func (h *CountHistory) Summary(now time.Time, window time.Duration) *v1alpha1.HistorySummary {
+	summary := &v1alpha1.HistorySummary{WindowMinutes: int32(window / time.Minute)}
+	for i, bucket := range h.Buckets(historyMinute, now.Add(-window)) {
+		summary.Created += bucket.Created
+		summary.Deleted += bucket.Deleted
+		summary.Failed += bucket.Failed
+		if i == 0 || bucket.RunningHigh > summary.RunningHigh {
+			summary.RunningHigh = bucket.RunningHigh
+		}
+		if i == 0 || bucket.RunningLow < summary.RunningLow {
+			summary.RunningLow = bucket.RunningLow
+		}
+	}
+	return summary
+}
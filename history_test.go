@@ -0,0 +1,40 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jayapriya90/k8s-pod-monitor/v1alpha1"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCountHistory(t *testing.T) {
+	start := time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC)
+	history := NewCountHistory(3, 2)
+	history.Record(start, 1, 0, 0, 1)
+	history.Record(start.Add(10*time.Second), 2, 0, 0, 3)
+	history.Record(start.Add(20*time.Second), 0, 1, 1, 2)
+	// two quiet minutes are filled with the running level
+	history.Record(start.Add(3*time.Minute), 1, 0, 0, 3)
+
+	minutes := history.Buckets(historyMinute, time.Time{})
+	require.Len(t, minutes, 3)
+	require.Equal(t, HistoryBucket{Start: start.Add(time.Minute), RunningHigh: 2, RunningLow: 2}, minutes[0])
+	require.Equal(t, HistoryBucket{Start: start.Add(3 * time.Minute), Created: 1, RunningHigh: 3, RunningLow: 2}, minutes[2])
+
+	hours := history.Buckets(historyHour, time.Time{})
+	require.Equal(t, []HistoryBucket{{Start: start, Created: 4, Deleted: 1, Failed: 1, RunningHigh: 3, RunningLow: 1}}, hours)
+
+	summary := history.Summary(start.Add(3*time.Minute), 2*time.Minute)
+	require.Equal(t, v1alpha1.HistorySummary{WindowMinutes: 2, Created: 1, RunningHigh: 3, RunningLow: 2}, *summary)
+}
+
+func TestCountHistoryDisabledResolution(t *testing.T) {
+	start := time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC)
+	history := NewCountHistory(0, 2)
+	history.Record(start, 1, 0, 0, 1)
+
+	require.Empty(t, history.Buckets(historyMinute, time.Time{}))
+	require.Len(t, history.Buckets(historyHour, time.Time{}), 1)
+	require.Equal(t, int32(0), history.Summary(start, time.Hour).Created)
+}
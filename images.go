@@ -0,0 +1,253 @@
+package main
+
+import (
+	"encoding/csv"
+	"io"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jayapriya90/k8s-pod-monitor/v1alpha1"
+	core_v1 "k8s.io/api/core/v1"
+)
+
+const (
+	// defaultRegistry is the registry of images without one
+	defaultRegistry = "docker.io"
+	// reasonImageDenied marks images matching a pattern of the deny list
+	reasonImageDenied = "Denied"
+	// reasonRegistryNotAllowed marks images from registries outside the allow list
+	reasonRegistryNotAllowed = "RegistryNotAllowed"
+)
+
+// ImageRecord counts the containers of running pods using an image, returned by /api/v1/images
+type ImageRecord struct {
+	Image      string `json:"image"`
+	Registry   string `json:"registry"`
+	Repository string `json:"repository"`
+	Tag        string `json:"tag,omitempty"`
+	// Digests are the resolved digests reported by the container statuses
+	Digests    []string         `json:"digests,omitempty"`
+	Containers int32            `json:"containers"`
+	Pods       int32            `json:"pods"`
+	Namespaces map[string]int32 `json:"namespaces"`
+}
+
+// RegistryCount counts the containers of running pods pulling from a registry
+type RegistryCount struct {
+	Registry   string `json:"registry"`
+	Images     int32  `json:"images"`
+	Containers int32  `json:"containers"`
+}
+
+// ImageInventory is the inventory of the images used by running pods
+type ImageInventory struct {
+	Images     []ImageRecord   `json:"images"`
+	Registries []RegistryCount `json:"registries"`
+}
+
+// parseImage splits an image reference into registry, repository, tag and
+// digest, applying the defaults of the docker CLI: images without registry
+// come from docker.io, official images live under library/
+func parseImage(image string) (string, string, string, string) {
+	var digest string
+	if i := strings.Index(image, "@"); i >= 0 {
+		image, digest = image[:i], image[i+1:]
+	}
+	var tag string
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		image, tag = image[:i], image[i+1:]
+	}
+	if tag == "" && digest == "" {
+		tag = "latest"
+	}
+	registry, repository := defaultRegistry, image
+	if i := strings.Index(image, "/"); i >= 0 && (strings.ContainsAny(image[:i], ".:") || image[:i] == "localhost") {
+		registry, repository = image[:i], image[i+1:]
+	}
+	if registry == defaultRegistry && !strings.Contains(repository, "/") {
+		repository = "library/" + repository
+	}
+	return registry, repository, tag, digest
+}
+
+// normalizeImage returns the fully qualified form of an image reference
+func normalizeImage(image string) string {
+	registry, repository, tag, digest := parseImage(image)
+	normalized := registry + "/" + repository
+	if tag != "" {
+		normalized += ":" + tag
+	}
+	if digest != "" {
+		normalized += "@" + digest
+	}
+	return normalized
+}
+
+// imageDigest returns the digest of the image a container runs, from the
+// imageID of its status when the runtime reports a repository digest
+func imageDigest(pod *core_v1.Pod, container string) string {
+	for _, status := range containerStatuses(pod) {
+		if status.Name == container {
+			if i := strings.LastIndex(status.ImageID, "@"); i >= 0 {
+				return status.ImageID[i+1:]
+			}
+		}
+	}
+	return ""
+}
+
+// imageInventory counts the images of the containers of running pods per
+// image, registry and namespace. Images are sorted by name, registries by containers
+func imageInventory(pods []*core_v1.Pod) ImageInventory {
+	images := make(map[string]*ImageRecord)
+	for _, pod := range pods {
+		if pod.Status.Phase != core_v1.PodRunning {
+			continue
+		}
+		counted := make(map[string]bool)
+		for _, container := range podContainers(pod) {
+			name := normalizeImage(container.Image)
+			record, exists := images[name]
+			if !exists {
+				registry, repository, tag, _ := parseImage(container.Image)
+				record = &ImageRecord{Image: name, Registry: registry, Repository: repository, Tag: tag, Namespaces: make(map[string]int32)}
+				images[name] = record
+			}
+			record.Containers++
+			record.Namespaces[pod.Namespace]++
+			if !counted[name] {
+				counted[name] = true
+				record.Pods++
+			}
+			if digest := imageDigest(pod, container.Name); digest != "" && !containsString(record.Digests, digest) {
+				record.Digests = append(record.Digests, digest)
+			}
+		}
+	}
+
+	inventory := ImageInventory{Images: []ImageRecord{}, Registries: []RegistryCount{}}
+	registries := make(map[string]*RegistryCount)
+	for _, record := range images {
+		sort.Strings(record.Digests)
+		inventory.Images = append(inventory.Images, *record)
+		registry, exists := registries[record.Registry]
+		if !exists {
+			registry = &RegistryCount{Registry: record.Registry}
+			registries[record.Registry] = registry
+		}
+		registry.Images++
+		registry.Containers += record.Containers
+	}
+	for _, registry := range registries {
+		inventory.Registries = append(inventory.Registries, *registry)
+	}
+	sort.Slice(inventory.Images, func(i, j int) bool {
+		return inventory.Images[i].Image < inventory.Images[j].Image
+	})
+	sort.Slice(inventory.Registries, func(i, j int) bool {
+		a, b := inventory.Registries[i], inventory.Registries[j]
+		if a.Containers != b.Containers {
+			return a.Containers > b.Containers
+		}
+		return a.Registry < b.Registry
+	})
+	return inventory
+}
+
+// writeImagesCSV writes one line per image and namespace
+func writeImagesCSV(w io.Writer, inventory ImageInventory) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"image", "registry", "repository", "tag", "digests", "namespace", "containers"})
+	for _, record := range inventory.Images {
+		namespaces := make([]string, 0, len(record.Namespaces))
+		for namespace := range record.Namespaces {
+			namespaces = append(namespaces, namespace)
+		}
+		sort.Strings(namespaces)
+		for _, namespace := range namespaces {
+			writer.Write([]string{
+				record.Image,
+				record.Registry,
+				record.Repository,
+				record.Tag,
+				strings.Join(record.Digests, " "),
+				namespace,
+				strconv.Itoa(int(record.Namespaces[namespace])),
+			})
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// imageCompliance returns why an image is not compliant with the spec, or an
+// empty reason. Deny patterns are matched against the image as written and
+// its fully qualified form
+func imageCompliance(spec *v1alpha1.ImagePolicySpec, image string) string {
+	normalized := normalizeImage(image)
+	for _, pattern := range spec.DeniedImages {
+		if matched, _ := path.Match(pattern, image); matched {
+			return reasonImageDenied
+		}
+		if matched, _ := path.Match(pattern, normalized); matched {
+			return reasonImageDenied
+		}
+	}
+	if len(spec.AllowedRegistries) > 0 {
+		registry, _, _, _ := parseImage(image)
+		if !containsString(spec.AllowedRegistries, registry) {
+			return reasonRegistryNotAllowed
+		}
+	}
+	return ""
+}
+
+// imagesStatus lists the containers of running pods whose image is not
+// compliant with the spec
+func imagesStatus(spec *v1alpha1.ImagePolicySpec, pods []*core_v1.Pod) *v1alpha1.ImagesStatus {
+	status := &v1alpha1.ImagesStatus{}
+	for _, pod := range pods {
+		if pod.Status.Phase != core_v1.PodRunning || containsString(spec.ExcludedNamespaces, pod.Namespace) {
+			continue
+		}
+		nonCompliant := false
+		for _, container := range podContainers(pod) {
+			if reason := imageCompliance(spec, container.Image); reason != "" {
+				nonCompliant = true
+				status.NonCompliant = append(status.NonCompliant, v1alpha1.NonCompliantImage{
+					Namespace: pod.Namespace,
+					Pod:       pod.Name,
+					Container: container.Name,
+					Image:     container.Image,
+					Reason:    reason,
+				})
+			}
+		}
+		if nonCompliant {
+			status.NonCompliantPods++
+		}
+	}
+	sort.Slice(status.NonCompliant, func(i, j int) bool {
+		a, b := status.NonCompliant[i], status.NonCompliant[j]
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		if a.Pod != b.Pod {
+			return a.Pod < b.Pod
+		}
+		return a.Container < b.Container
+	})
+	return status
+}
+
+// containsString returns whether values contains value
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jayapriya90/k8s-pod-monitor/v1alpha1"
+	"github.com/stretchr/testify/require"
+	core_v1 "k8s.io/api/core/v1"
+)
+
+func TestImagesStatus(t *testing.T) {
+	spec := &v1alpha1.ImagePolicySpec{
+		DeniedImages:       []string{"docker.io/library/nginx:1.14*"},
+		AllowedRegistries:  []string{"docker.io", "registry.local:5000"},
+		ExcludedNamespaces: []string{"kube-system"},
+	}
+	pod := newTestPod("web", "a", core_v1.PodRunning, time.Now())
+	pod.Spec.Containers = []core_v1.Container{
+		{Name: "nginx", Image: "nginx:1.14.2"},
+		{Name: "app", Image: "registry.local:5000/app@sha256:abc"},
+		{Name: "proxy", Image: "quay.io/envoy/envoy:v1"},
+	}
+	excluded := newTestPod("kube-system", "b", core_v1.PodRunning, time.Now())
+	excluded.Spec.Containers = []core_v1.Container{{Name: "dns", Image: "k8s.gcr.io/coredns:1.6"}}
+
+	status := imagesStatus(spec, []*core_v1.Pod{&pod, &excluded})
+	require.Equal(t, int32(1), status.NonCompliantPods)
+	require.Equal(t, []v1alpha1.NonCompliantImage{
+		{Namespace: "web", Pod: "a", Container: "nginx", Image: "nginx:1.14.2", Reason: reasonImageDenied},
+		{Namespace: "web", Pod: "a", Container: "proxy", Image: "quay.io/envoy/envoy:v1", Reason: reasonRegistryNotAllowed},
+	}, status.NonCompliant)
+}
@@ -0,0 +1,383 @@
+package main
+
+import (
+	apps_v1 "k8s.io/api/apps/v1"
+	batch_v1 "k8s.io/api/batch/v1"
+	batch_v1beta1 "k8s.io/api/batch/v1beta1"
+	core_v1 "k8s.io/api/core/v1"
+	networking_v1 "k8s.io/api/networking/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// NewPodInformer creates an informer watching the pods of namespace
+// (meta_v1.NamespaceAll for all namespaces), indexed by namespace
+func NewPodInformer(client kubernetes.Interface, namespace string) cache.SharedIndexInformer {
+	return cache.NewSharedIndexInformer(
+		// the ListWatch contains two functions that informer requires
+		// ListFunc - to list resources and
+		// WatchFunc - to watch resources
+		&cache.ListWatch{
+			ListFunc: func(options meta_v1.ListOptions) (runtime.Object, error) {
+				return client.CoreV1().Pods(namespace).List(options)
+			},
+			WatchFunc: func(options meta_v1.ListOptions) (watch.Interface, error) {
+				return client.CoreV1().Pods(namespace).Watch(options)
+			},
+		},
+		&core_v1.Pod{}, // the target type (Pod)
+		0,              // no resync (period of 0)
+		// index pods by namespace for the query API
+		cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc},
+	)
+}
+
+// NewNodeInformer creates an informer watching all nodes
+func NewNodeInformer(client kubernetes.Interface) cache.SharedIndexInformer {
+	return cache.NewSharedIndexInformer(
+		&cache.ListWatch{
+			ListFunc: func(options meta_v1.ListOptions) (runtime.Object, error) {
+				return client.CoreV1().Nodes().List(options)
+			},
+			WatchFunc: func(options meta_v1.ListOptions) (watch.Interface, error) {
+				return client.CoreV1().Nodes().Watch(options)
+			},
+		},
+		&core_v1.Node{},
+		0,
+		cache.Indexers{},
+	)
+}
+
+// eventPodUIDIndex indexes events by the UID of the pod they involve
+const eventPodUIDIndex = "involvedObject.uid"
+
+// eventPodUIDIndexFunc returns the UID of the pod an event involves
+func eventPodUIDIndexFunc(obj interface{}) ([]string, error) {
+	event, ok := obj.(*core_v1.Event)
+	if !ok {
+		return nil, nil
+	}
+	return []string{string(event.InvolvedObject.UID)}, nil
+}
+
+// NewPodEventInformer creates an informer watching the events that involve
+// pods, indexed by pod UID
+func NewPodEventInformer(client kubernetes.Interface) cache.SharedIndexInformer {
+	selector := fields.OneTermEqualSelector("involvedObject.kind", "Pod").String()
+	return cache.NewSharedIndexInformer(
+		&cache.ListWatch{
+			ListFunc: func(options meta_v1.ListOptions) (runtime.Object, error) {
+				options.FieldSelector = selector
+				return client.CoreV1().Events(meta_v1.NamespaceAll).List(options)
+			},
+			WatchFunc: func(options meta_v1.ListOptions) (watch.Interface, error) {
+				options.FieldSelector = selector
+				return client.CoreV1().Events(meta_v1.NamespaceAll).Watch(options)
+			},
+		},
+		&core_v1.Event{},
+		0,
+		cache.Indexers{eventPodUIDIndex: eventPodUIDIndexFunc},
+	)
+}
+
+// NewJobInformer creates an informer watching all Jobs
+func NewJobInformer(client kubernetes.Interface) cache.SharedIndexInformer {
+	return cache.NewSharedIndexInformer(
+		&cache.ListWatch{
+			ListFunc: func(options meta_v1.ListOptions) (runtime.Object, error) {
+				return client.BatchV1().Jobs(meta_v1.NamespaceAll).List(options)
+			},
+			WatchFunc: func(options meta_v1.ListOptions) (watch.Interface, error) {
+				return client.BatchV1().Jobs(meta_v1.NamespaceAll).Watch(options)
+			},
+		},
+		&batch_v1.Job{},
+		0,
+		cache.Indexers{},
+	)
+}
+
+// servesCronJobs reports whether the API server serves the batch/v1beta1
+// CronJobs the CronJob informer watches
+func servesCronJobs(client kubernetes.Interface) bool {
+	resources, err := client.Discovery().ServerResourcesForGroupVersion(batch_v1beta1.SchemeGroupVersion.String())
+	if err != nil {
+		return false
+	}
+	for _, resource := range resources.APIResources {
+		if resource.Name == "cronjobs" {
+			return true
+		}
+	}
+	return false
+}
+
+// NewCronJobInformer creates an informer watching all CronJobs
+func NewCronJobInformer(client kubernetes.Interface) cache.SharedIndexInformer {
+	return cache.NewSharedIndexInformer(
+		&cache.ListWatch{
+			ListFunc: func(options meta_v1.ListOptions) (runtime.Object, error) {
+				return client.BatchV1beta1().CronJobs(meta_v1.NamespaceAll).List(options)
+			},
+			WatchFunc: func(options meta_v1.ListOptions) (watch.Interface, error) {
+				return client.BatchV1beta1().CronJobs(meta_v1.NamespaceAll).Watch(options)
+			},
+		},
+		&batch_v1beta1.CronJob{},
+		0,
+		cache.Indexers{},
+	)
+}
+
+// NewDeploymentInformer creates an informer watching all Deployments
+func NewDeploymentInformer(client kubernetes.Interface) cache.SharedIndexInformer {
+	return cache.NewSharedIndexInformer(
+		&cache.ListWatch{
+			ListFunc: func(options meta_v1.ListOptions) (runtime.Object, error) {
+				return client.AppsV1().Deployments(meta_v1.NamespaceAll).List(options)
+			},
+			WatchFunc: func(options meta_v1.ListOptions) (watch.Interface, error) {
+				return client.AppsV1().Deployments(meta_v1.NamespaceAll).Watch(options)
+			},
+		},
+		&apps_v1.Deployment{},
+		0,
+		cache.Indexers{},
+	)
+}
+
+// NewStatefulSetInformer creates an informer watching all StatefulSets
+func NewStatefulSetInformer(client kubernetes.Interface) cache.SharedIndexInformer {
+	return cache.NewSharedIndexInformer(
+		&cache.ListWatch{
+			ListFunc: func(options meta_v1.ListOptions) (runtime.Object, error) {
+				return client.AppsV1().StatefulSets(meta_v1.NamespaceAll).List(options)
+			},
+			WatchFunc: func(options meta_v1.ListOptions) (watch.Interface, error) {
+				return client.AppsV1().StatefulSets(meta_v1.NamespaceAll).Watch(options)
+			},
+		},
+		&apps_v1.StatefulSet{},
+		0,
+		cache.Indexers{},
+	)
+}
+
+// NewReplicaSetInformer creates an informer watching all ReplicaSets
+func NewReplicaSetInformer(client kubernetes.Interface) cache.SharedIndexInformer {
+	return cache.NewSharedIndexInformer(
+		&cache.ListWatch{
+			ListFunc: func(options meta_v1.ListOptions) (runtime.Object, error) {
+				return client.AppsV1().ReplicaSets(meta_v1.NamespaceAll).List(options)
+			},
+			WatchFunc: func(options meta_v1.ListOptions) (watch.Interface, error) {
+				return client.AppsV1().ReplicaSets(meta_v1.NamespaceAll).Watch(options)
+			},
+		},
+		&apps_v1.ReplicaSet{},
+		0,
+		cache.Indexers{},
+	)
+}
+
+// NewDaemonSetInformer creates an informer watching all DaemonSets
+func NewDaemonSetInformer(client kubernetes.Interface) cache.SharedIndexInformer {
+	return cache.NewSharedIndexInformer(
+		&cache.ListWatch{
+			ListFunc: func(options meta_v1.ListOptions) (runtime.Object, error) {
+				return client.AppsV1().DaemonSets(meta_v1.NamespaceAll).List(options)
+			},
+			WatchFunc: func(options meta_v1.ListOptions) (watch.Interface, error) {
+				return client.AppsV1().DaemonSets(meta_v1.NamespaceAll).Watch(options)
+			},
+		},
+		&apps_v1.DaemonSet{},
+		0,
+		cache.Indexers{},
+	)
+}
+
+// NewNetworkPolicyInformer creates an informer watching all NetworkPolicies
+func NewNetworkPolicyInformer(client kubernetes.Interface) cache.SharedIndexInformer {
+	return cache.NewSharedIndexInformer(
+		&cache.ListWatch{
+			ListFunc: func(options meta_v1.ListOptions) (runtime.Object, error) {
+				return client.NetworkingV1().NetworkPolicies(meta_v1.NamespaceAll).List(options)
+			},
+			WatchFunc: func(options meta_v1.ListOptions) (watch.Interface, error) {
+				return client.NetworkingV1().NetworkPolicies(meta_v1.NamespaceAll).Watch(options)
+			},
+		},
+		&networking_v1.NetworkPolicy{},
+		0,
+		cache.Indexers{},
+	)
+}
+
+// stripWatch applies strip to the objects of the events of a watch
+func stripWatch(w watch.Interface, err error, strip func(obj runtime.Object)) (watch.Interface, error) {
+	if err != nil {
+		return nil, err
+	}
+	return watch.Filter(w, func(event watch.Event) (watch.Event, bool) {
+		strip(event.Object)
+		return event, true
+	}), nil
+}
+
+// stripConfigMap drops the data of a ConfigMap, only its existence is checked
+func stripConfigMap(obj runtime.Object) {
+	if configMap, ok := obj.(*core_v1.ConfigMap); ok {
+		configMap.Data, configMap.BinaryData = nil, nil
+	}
+}
+
+// stripSecret drops the data of a Secret, only its existence is checked
+func stripSecret(obj runtime.Object) {
+	if secret, ok := obj.(*core_v1.Secret); ok {
+		secret.Data, secret.StringData = nil, nil
+	}
+}
+
+// NewConfigMapInformer creates an informer watching all ConfigMaps. Their
+// data is dropped before it reaches the cache
+func NewConfigMapInformer(client kubernetes.Interface) cache.SharedIndexInformer {
+	return cache.NewSharedIndexInformer(
+		&cache.ListWatch{
+			ListFunc: func(options meta_v1.ListOptions) (runtime.Object, error) {
+				list, err := client.CoreV1().ConfigMaps(meta_v1.NamespaceAll).List(options)
+				if err != nil {
+					return nil, err
+				}
+				for i := range list.Items {
+					stripConfigMap(&list.Items[i])
+				}
+				return list, nil
+			},
+			WatchFunc: func(options meta_v1.ListOptions) (watch.Interface, error) {
+				w, err := client.CoreV1().ConfigMaps(meta_v1.NamespaceAll).Watch(options)
+				return stripWatch(w, err, stripConfigMap)
+			},
+		},
+		&core_v1.ConfigMap{},
+		0,
+		cache.Indexers{},
+	)
+}
+
+// NewSecretInformer creates an informer watching all Secrets. Their data is
+// dropped before it reaches the cache
+func NewSecretInformer(client kubernetes.Interface) cache.SharedIndexInformer {
+	return cache.NewSharedIndexInformer(
+		&cache.ListWatch{
+			ListFunc: func(options meta_v1.ListOptions) (runtime.Object, error) {
+				list, err := client.CoreV1().Secrets(meta_v1.NamespaceAll).List(options)
+				if err != nil {
+					return nil, err
+				}
+				for i := range list.Items {
+					stripSecret(&list.Items[i])
+				}
+				return list, nil
+			},
+			WatchFunc: func(options meta_v1.ListOptions) (watch.Interface, error) {
+				w, err := client.CoreV1().Secrets(meta_v1.NamespaceAll).Watch(options)
+				return stripWatch(w, err, stripSecret)
+			},
+		},
+		&core_v1.Secret{},
+		0,
+		cache.Indexers{},
+	)
+}
+
+// NewPersistentVolumeClaimInformer creates an informer watching all PersistentVolumeClaims
+func NewPersistentVolumeClaimInformer(client kubernetes.Interface) cache.SharedIndexInformer {
+	return cache.NewSharedIndexInformer(
+		&cache.ListWatch{
+			ListFunc: func(options meta_v1.ListOptions) (runtime.Object, error) {
+				return client.CoreV1().PersistentVolumeClaims(meta_v1.NamespaceAll).List(options)
+			},
+			WatchFunc: func(options meta_v1.ListOptions) (watch.Interface, error) {
+				return client.CoreV1().PersistentVolumeClaims(meta_v1.NamespaceAll).Watch(options)
+			},
+		},
+		&core_v1.PersistentVolumeClaim{},
+		0,
+		cache.Indexers{},
+	)
+}
+
+// NewServiceAccountInformer creates an informer watching all ServiceAccounts
+func NewServiceAccountInformer(client kubernetes.Interface) cache.SharedIndexInformer {
+	return cache.NewSharedIndexInformer(
+		&cache.ListWatch{
+			ListFunc: func(options meta_v1.ListOptions) (runtime.Object, error) {
+				return client.CoreV1().ServiceAccounts(meta_v1.NamespaceAll).List(options)
+			},
+			WatchFunc: func(options meta_v1.ListOptions) (watch.Interface, error) {
+				return client.CoreV1().ServiceAccounts(meta_v1.NamespaceAll).Watch(options)
+			},
+		},
+		&core_v1.ServiceAccount{},
+		0,
+		cache.Indexers{},
+	)
+}
+
+// NewServiceInformer creates an informer watching all Services
+func NewServiceInformer(client kubernetes.Interface) cache.SharedIndexInformer {
+	return cache.NewSharedIndexInformer(
+		&cache.ListWatch{
+			ListFunc: func(options meta_v1.ListOptions) (runtime.Object, error) {
+				return client.CoreV1().Services(meta_v1.NamespaceAll).List(options)
+			},
+			WatchFunc: func(options meta_v1.ListOptions) (watch.Interface, error) {
+				return client.CoreV1().Services(meta_v1.NamespaceAll).Watch(options)
+			},
+		},
+		&core_v1.Service{},
+		0,
+		cache.Indexers{},
+	)
+}
+
+// NewEndpointsInformer creates an informer watching all Endpoints
+func NewEndpointsInformer(client kubernetes.Interface) cache.SharedIndexInformer {
+	return cache.NewSharedIndexInformer(
+		&cache.ListWatch{
+			ListFunc: func(options meta_v1.ListOptions) (runtime.Object, error) {
+				return client.CoreV1().Endpoints(meta_v1.NamespaceAll).List(options)
+			},
+			WatchFunc: func(options meta_v1.ListOptions) (watch.Interface, error) {
+				return client.CoreV1().Endpoints(meta_v1.NamespaceAll).Watch(options)
+			},
+		},
+		&core_v1.Endpoints{},
+		0,
+		cache.Indexers{},
+	)
+}
+
+// NewResourceQuotaInformer creates an informer watching all ResourceQuotas
+func NewResourceQuotaInformer(client kubernetes.Interface) cache.SharedIndexInformer {
+	return cache.NewSharedIndexInformer(
+		&cache.ListWatch{
+			ListFunc: func(options meta_v1.ListOptions) (runtime.Object, error) {
+				return client.CoreV1().ResourceQuotas(meta_v1.NamespaceAll).List(options)
+			},
+			WatchFunc: func(options meta_v1.ListOptions) (watch.Interface, error) {
+				return client.CoreV1().ResourceQuotas(meta_v1.NamespaceAll).Watch(options)
+			},
+		},
+		&core_v1.ResourceQuota{},
+		0,
+		cache.Indexers{},
+	)
+}
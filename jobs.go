@@ -0,0 +1,224 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jayapriya90/k8s-pod-monitor/v1alpha1"
+	batch_v1 "k8s.io/api/batch/v1"
+	batch_v1beta1 "k8s.io/api/batch/v1beta1"
+	core_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// cronJobStartGrace is how late a scheduled CronJob run may start before it is reported as missed
+const cronJobStartGrace = 5 * time.Minute
+
+// jobRun is a finished run of a Job
+type jobRun struct {
+	namespace string
+	job       string
+	// cronJob is the name of the CronJob that created the Job, if any
+	cronJob   string
+	succeeded bool
+	start     time.Time
+	end       time.Time
+	// retries are the pods of the Job that failed
+	retries int32
+}
+
+// JobTracker records the outcome of Jobs as they finish and keeps them for a
+// rolling window, so the statistics survive the Jobs being cleaned up
+type JobTracker struct {
+	mu     sync.Mutex
+	window time.Duration
+	// runs holds the finished runs by Job UID
+	runs map[string]jobRun
+}
+
+// NewJobTracker returns a tracker keeping finished runs for window
+func NewJobTracker(window time.Duration) *JobTracker {
+	return &JobTracker{window: window, runs: make(map[string]jobRun)}
+}
+
+// Observe records a Job once it completed or failed
+func (j *JobTracker) Observe(obj interface{}) {
+	job, ok := obj.(*batch_v1.Job)
+	if !ok {
+		return
+	}
+	run, finished := finishedJobRun(job)
+	if !finished {
+		return
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.runs[string(job.UID)] = run
+}
+
+// finishedJobRun returns the run of a Job that has a true Complete or Failed condition
+func finishedJobRun(job *batch_v1.Job) (jobRun, bool) {
+	for _, condition := range job.Status.Conditions {
+		if condition.Status != core_v1.ConditionTrue || (condition.Type != batch_v1.JobComplete && condition.Type != batch_v1.JobFailed) {
+			continue
+		}
+		run := jobRun{
+			namespace: job.Namespace,
+			job:       job.Name,
+			succeeded: condition.Type == batch_v1.JobComplete,
+			start:     job.CreationTimestamp.Time,
+			end:       condition.LastTransitionTime.Time,
+			retries:   job.Status.Failed,
+		}
+		if job.Status.StartTime != nil {
+			run.start = job.Status.StartTime.Time
+		}
+		if run.succeeded && job.Status.CompletionTime != nil {
+			run.end = job.Status.CompletionTime.Time
+		}
+		for _, ref := range job.OwnerReferences {
+			if ref.Controller != nil && *ref.Controller && ref.Kind == "CronJob" {
+				run.cronJob = ref.Name
+			}
+		}
+		return run, true
+	}
+	return jobRun{}, false
+}
+
+// jobOutcome is an outcome with the total duration its average is computed from
+type jobOutcome struct {
+	v1alpha1.JobOutcome
+	totalDurationSeconds int64
+}
+
+// jobOutcomes sums runs per name
+type jobOutcomes map[string]*jobOutcome
+
+func (o jobOutcomes) add(name string, run jobRun) {
+	outcome, exists := o[name]
+	if !exists {
+		outcome = &jobOutcome{JobOutcome: v1alpha1.JobOutcome{Name: name}}
+		o[name] = outcome
+	}
+	outcome.Runs++
+	if run.succeeded {
+		outcome.Succeeded++
+	} else {
+		outcome.Failed++
+	}
+	outcome.Retries += run.retries
+	duration := int64(run.end.Sub(run.start).Seconds())
+	outcome.totalDurationSeconds += duration
+	if duration > outcome.MaxDurationSeconds {
+		outcome.MaxDurationSeconds = duration
+	}
+	if run.end.After(outcome.LastRunTime.Time) {
+		outcome.LastRunTime = meta_v1.NewTime(run.end)
+		outcome.LastRunSucceeded = run.succeeded
+	}
+}
+
+// list returns the outcomes sorted by name with the averages computed
+func (o jobOutcomes) list() []v1alpha1.JobOutcome {
+	outcomes := make([]v1alpha1.JobOutcome, 0, len(o))
+	for _, outcome := range o {
+		outcome.AverageDurationSeconds = outcome.totalDurationSeconds / int64(outcome.Runs)
+		outcome.SuccessRatePercent = outcome.Succeeded * 100 / outcome.Runs
+		outcomes = append(outcomes, outcome.JobOutcome)
+	}
+	sort.Slice(outcomes, func(i, j int) bool {
+		return outcomes[i].Name < outcomes[j].Name
+	})
+	return outcomes
+}
+
+// Status reports the runs that finished within the window per Job and per
+// CronJob. Runs of CronJobs are only reported per CronJob, as every run is a
+// new Job. cronJobs is the content of the CronJob cache
+func (j *JobTracker) Status(now time.Time, cronJobs []interface{}) *v1alpha1.JobsStatus {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	jobs, crons := jobOutcomes{}, jobOutcomes{}
+	cutoff := now.Add(-j.window)
+	for uid, run := range j.runs {
+		if run.end.Before(cutoff) {
+			delete(j.runs, uid)
+			continue
+		}
+		if run.cronJob != "" {
+			crons.add(run.namespace+"/"+run.cronJob, run)
+		} else {
+			jobs.add(run.namespace+"/"+run.job, run)
+		}
+	}
+
+	status := &v1alpha1.JobsStatus{Window: j.window.String(), Jobs: jobs.list(), CronJobs: crons.list()}
+	for _, obj := range cronJobs {
+		cronJob, ok := obj.(*batch_v1beta1.CronJob)
+		if !ok {
+			continue
+		}
+		name := cronJob.Namespace + "/" + cronJob.Name
+		if outcome, exists := crons[name]; exists && !outcome.LastRunSucceeded {
+			status.FailingCronJobs = append(status.FailingCronJobs, name)
+		}
+		if missed, err := missedCronJobRun(cronJob, now, j.window); err == nil && missed {
+			status.MissedCronJobs = append(status.MissedCronJobs, name)
+		}
+	}
+	sort.Strings(status.FailingCronJobs)
+	sort.Strings(status.MissedCronJobs)
+	return status
+}
+
+// missedCronJobRun reports whether a CronJob that is not suspended was
+// scheduled within the window but has not started for longer than the grace period
+func missedCronJobRun(cronJob *batch_v1beta1.CronJob, now time.Time, window time.Duration) (bool, error) {
+	if cronJob.Spec.Suspend != nil && *cronJob.Spec.Suspend {
+		return false, nil
+	}
+	schedule, err := parseCronSchedule(cronJob.Spec.Schedule)
+	if err != nil {
+		return false, err
+	}
+	last := cronJob.CreationTimestamp.Time
+	if cronJob.Status.LastScheduleTime != nil {
+		last = cronJob.Status.LastScheduleTime.Time
+	}
+	if cutoff := now.Add(-window); last.Before(cutoff) {
+		last = cutoff
+	}
+	grace := cronJobStartGrace
+	if deadline := cronJob.Spec.StartingDeadlineSeconds; deadline != nil && time.Duration(*deadline)*time.Second > grace {
+		grace = time.Duration(*deadline) * time.Second
+	}
+	_, missed := schedule.next(last.UTC(), now.Add(-grace).UTC())
+	return missed, nil
+}
+
+// cronJobsFailingCondition reports CronJobs whose last run failed or that missed a scheduled run
+func cronJobsFailingCondition(jobs *v1alpha1.JobsStatus) v1alpha1.PodMonitorCondition {
+	if len(jobs.FailingCronJobs) == 0 && len(jobs.MissedCronJobs) == 0 {
+		return v1alpha1.PodMonitorCondition{Type: conditionCronJobsFailing, Status: meta_v1.ConditionFalse, Reason: "CronJobsSucceeding"}
+	}
+	var message string
+	if len(jobs.FailingCronJobs) > 0 {
+		message = fmt.Sprintf("last run failed: %s", summarizeKeys(jobs.FailingCronJobs, 5))
+	}
+	if len(jobs.MissedCronJobs) > 0 {
+		if message != "" {
+			message += "; "
+		}
+		message += fmt.Sprintf("scheduled run did not start: %s", summarizeKeys(jobs.MissedCronJobs, 5))
+	}
+	return v1alpha1.PodMonitorCondition{
+		Type:    conditionCronJobsFailing,
+		Status:  meta_v1.ConditionTrue,
+		Reason:  "CronJobRunsFailing",
+		Message: message,
+	}
+}
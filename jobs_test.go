@@ -0,0 +1,54 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	batch_v1 "k8s.io/api/batch/v1"
+	batch_v1beta1 "k8s.io/api/batch/v1beta1"
+	core_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestJobTrackerOutcomes(t *testing.T) {
+	now := time.Date(2019, 10, 1, 12, 0, 0, 0, time.UTC)
+	controller := true
+	job := func(name string, conditionType batch_v1.JobConditionType, end time.Time, failedPods int32) *batch_v1.Job {
+		start := meta_v1.NewTime(end.Add(-time.Minute))
+		return &batch_v1.Job{
+			ObjectMeta: meta_v1.ObjectMeta{
+				Namespace: "batch", Name: name, UID: types.UID(name),
+				OwnerReferences: []meta_v1.OwnerReference{{Kind: "CronJob", Name: "nightly", Controller: &controller}},
+			},
+			Status: batch_v1.JobStatus{
+				StartTime:  &start,
+				Failed:     failedPods,
+				Conditions: []batch_v1.JobCondition{{Type: conditionType, Status: core_v1.ConditionTrue, LastTransitionTime: meta_v1.NewTime(end)}},
+			},
+		}
+	}
+
+	jobs := NewJobTracker(24 * time.Hour)
+	jobs.Observe(job("nightly-1", batch_v1.JobComplete, now.Add(-2*time.Hour), 1))
+	jobs.Observe(job("nightly-2", batch_v1.JobFailed, now.Add(-time.Hour), 6))
+	// outside of the window
+	jobs.Observe(job("nightly-0", batch_v1.JobComplete, now.Add(-48*time.Hour), 0))
+
+	cronJob := &batch_v1beta1.CronJob{
+		ObjectMeta: meta_v1.ObjectMeta{Namespace: "batch", Name: "nightly"},
+		Spec:       batch_v1beta1.CronJobSpec{Schedule: "0 */4 * * *"},
+		Status:     batch_v1beta1.CronJobStatus{LastScheduleTime: &meta_v1.Time{Time: now.Add(-5 * time.Hour)}},
+	}
+	status := jobs.Status(now, []interface{}{cronJob})
+	require.Empty(t, status.Jobs)
+	require.Len(t, status.CronJobs, 1)
+	require.Equal(t, int32(2), status.CronJobs[0].Runs)
+	require.Equal(t, int32(50), status.CronJobs[0].SuccessRatePercent)
+	require.Equal(t, int32(7), status.CronJobs[0].Retries)
+	require.Equal(t, int64(60), status.CronJobs[0].AverageDurationSeconds)
+	require.Equal(t, []string{"batch/nightly"}, status.FailingCronJobs)
+	// the 08:00 run never started
+	require.Equal(t, []string{"batch/nightly"}, status.MissedCronJobs)
+}
@@ -1,16 +1,15 @@
 package main
 
 import (
+	"flag"
 	"k8s.io/client-go/rest"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	log "github.com/Sirupsen/logrus"
-	api_v1 "k8s.io/api/core/v1"
 	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/clientcmd"
@@ -43,29 +42,59 @@ func GetKubernetesClient() (kubernetes.Interface, *rest.Config) {
 	return kubeClient, config
 }
 
+// subcommands maps the optional first argument to the command it runs.
+// Without a subcommand the binary runs the controller
+var subcommands = map[string]func(args []string) error{
+	"analyze": runAnalyze,
+	"top":     runTop,
+	"report":  runReport,
+}
+
 func main() {
+	if len(os.Args) > 1 {
+		if cmd, ok := subcommands[os.Args[1]]; ok {
+			if err := cmd(os.Args[2:]); err != nil {
+				log.Fatalf("%s: %v", os.Args[1], err)
+			}
+			return
+		}
+	}
+	runController()
+}
+
+// runController watches pods in all namespaces and keeps the pod-monitor resource up to date
+func runController() {
+	listenAddr := flag.String("listen", ":8080", "address the query API is served on. Empty disables it")
+	stuckAfter := flag.Duration("stuck-after", 5*time.Minute, "time after which a pending pod is reported as stuck")
+	streamHistory := flag.Int("stream-history", 1000, "number of stream events kept for clients resuming with Last-Event-ID")
+	streamBuffer := flag.Int("stream-buffer", 256, "number of stream events buffered per client before a slow client is disconnected")
+	teamLabel := flag.String("team-label", "team", "pod label usage is accounted by for chargeback")
+	usageRetention := flag.Duration("usage-retention", 31*24*time.Hour, "how long hourly usage is kept")
+	usageConfigMap := flag.String("usage-configmap", "pod-monitor-usage", "ConfigMap in the default namespace usage is persisted in. Empty disables persistence")
+	jobWindow := flag.Duration("job-window", 24*time.Hour, "rolling window Job and CronJob outcomes are reported over")
+	rolloutStallAfter := flag.Duration("rollout-stall-after", 10*time.Minute, "time after which a rollout that has not completed is reported as stalled")
+	configReferences := flag.Bool("config-references", false, "watch ConfigMaps and Secrets and report pods referencing missing ones")
+	networkCoverage := flag.Bool("network-policy-coverage", false, "watch NetworkPolicies and report running pods not selected by any")
+	quotaWindow := flag.Duration("quota-window", 6*time.Hour, "window the pod creation rate is measured over to forecast quota exhaustion")
+	quotaHorizon := flag.Duration("quota-horizon", 24*time.Hour, "namespaces forecast to exhaust a quota within this time are reported")
+	historyMinutes := flag.Int("history-minutes", 24*60, "number of per-minute buckets of pod counts kept. 0 disables them")
+	historyHours := flag.Int("history-hours", 7*24, "number of per-hour buckets of pod counts kept. 0 disables them")
+	churnDeviation := flag.Float64("churn-deviation", 3, "standard deviations above the baseline pod creation rate of an owner reported as churn")
+	churnMinCreations := flag.Int("churn-min-creations", 5, "pod creations per minute below which an owner is never reported as churn")
+	nodePodThreshold := flag.Float64("node-pod-threshold", 0.9, "fraction of the allocatable pods of a node above which it is reported as near its pod limit")
+	flag.Parse()
+	if *historyMinutes < 0 || *historyHours < 0 {
+		log.Fatalf("-history-minutes and -history-hours must not be negative")
+	}
+	if *streamHistory < 0 || *streamBuffer < 0 {
+		log.Fatalf("-stream-history and -stream-buffer must not be negative")
+	}
+
 	// get kubernetes client
 	client, config := GetKubernetesClient()
 
 	// create the informer to watch all the pods
-	informer := cache.NewSharedIndexInformer(
-		// the ListWatch contains two functions that informer requires
-		// ListFunc - to list resources and
-		// WatchFunc - to watch resources
-		&cache.ListWatch{
-			ListFunc: func(options meta_v1.ListOptions) (runtime.Object, error) {
-				// list pods from all namespaces
-				return client.CoreV1().Pods(meta_v1.NamespaceAll).List(options)
-			},
-			WatchFunc: func(options meta_v1.ListOptions) (watch.Interface, error) {
-				// watch pods from all namespaces
-				return client.CoreV1().Pods(meta_v1.NamespaceAll).Watch(options)
-			},
-		},
-		&api_v1.Pod{}, // the target type (Pod)
-		0,             // no resync (period of 0)
-		cache.Indexers{},
-	)
+	informer := NewPodInformer(client, meta_v1.NamespaceAll)
 
 	// create a queue to process the resources received by the informer
 	queue := workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
@@ -105,13 +134,136 @@ func main() {
 		},
 	})
 
+	// usage is persisted so chargeback reports survive restarts
+	var usageStore usagePersister
+	if *usageConfigMap != "" {
+		usageStore = NewUsageStore(client, meta_v1.NamespaceDefault, *usageConfigMap)
+	}
+
+	// nodes are only read from the cache, changes show up on the next status refresh
+	nodeInformer := NewNodeInformer(client)
+	// events of pods are correlated with the tracked pods by UID
+	eventInformer := NewPodEventInformer(client)
+	// Jobs are recorded as they finish, CronJobs are checked for missed runs
+	// when the API server still serves batch/v1beta1 CronJobs
+	jobInformer := NewJobInformer(client)
+	var cronJobInformer cache.SharedIndexInformer
+	var cronJobs cache.Store
+	if servesCronJobs(client) {
+		cronJobInformer = NewCronJobInformer(client)
+		cronJobs = cronJobInformer.GetStore()
+	} else {
+		log.Warn("batch/v1beta1 CronJobs are not served, missed CronJob runs are not reported")
+	}
+	jobs := NewJobTracker(*jobWindow)
+	// workloads provide the desired replicas running pods are compared with
+	workloadInformers := map[string]cache.SharedIndexInformer{
+		"Deployment":  NewDeploymentInformer(client),
+		"StatefulSet": NewStatefulSetInformer(client),
+		"ReplicaSet":  NewReplicaSetInformer(client),
+		"DaemonSet":   NewDaemonSetInformer(client),
+	}
+	// owners are the controllers pods are checked to still have
+	owners := map[string]cache.Store{"Job": jobInformer.GetStore()}
+	var workloads []cache.Store
+	for kind, informer := range workloadInformers {
+		workloads = append(workloads, informer.GetStore())
+		owners[kind] = informer.GetStore()
+	}
+	jobInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: jobs.Observe,
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			jobs.Observe(newObj)
+		},
+	})
+
+	// objects pods reference are looked up to find pods blocked by missing ones
+	// ConfigMaps and Secrets are only watched when enabled, as this needs access to all of them
+	claimInformer, serviceAccountInformer := NewPersistentVolumeClaimInformer(client), NewServiceAccountInformer(client)
+	referenceInformers := []cache.SharedIndexInformer{claimInformer, serviceAccountInformer}
+	var configMaps, secrets cache.Store
+	if *configReferences {
+		configMapInformer, secretInformer := NewConfigMapInformer(client), NewSecretInformer(client)
+		referenceInformers = append(referenceInformers, configMapInformer, secretInformer)
+		configMaps, secrets = configMapInformer.GetStore(), secretInformer.GetStore()
+	}
+	references := NewReferenceChecker(configMaps, secrets, claimInformer.GetStore(), serviceAccountInformer.GetStore())
+
+	// Services and their Endpoints are joined with the running pods
+	serviceInformer, endpointsInformer := NewServiceInformer(client), NewEndpointsInformer(client)
+
+	// quotas are compared with the usage of the pods of their namespace
+	quotaInformer := NewResourceQuotaInformer(client)
+
+	// broker fans count changes and pod transitions out to stream clients
+	broker := NewEventBroker(*streamHistory, *streamBuffer)
+
+	secondary := []cache.SharedIndexInformer{nodeInformer, eventInformer, jobInformer}
+	if cronJobInformer != nil {
+		secondary = append(secondary, cronJobInformer)
+	}
+	for _, informer := range workloadInformers {
+		secondary = append(secondary, informer)
+	}
+	secondary = append(secondary, referenceInformers...)
+	secondary = append(secondary, serviceInformer, endpointsInformer, quotaInformer)
+	var networkPolicies cache.Store
+	if *networkCoverage {
+		networkPolicyInformer := NewNetworkPolicyInformer(client)
+		secondary = append(secondary, networkPolicyInformer)
+		networkPolicies = networkPolicyInformer.GetStore()
+	}
+	// the controller only waits for the pod informer, the handler checks the others
+	synced := make(map[cache.Store]cache.InformerSynced, len(secondary))
+	for _, informer := range secondary {
+		synced[informer.GetStore()] = informer.HasSynced
+	}
+
 	// construct the Controller object
 	controller := Controller{
 		logger:    log.NewEntry(log.New()),
 		clientset: client,
 		informer:  informer,
+		secondary: secondary,
 		queue:     queue,
-		handler:   NewPodHandler(config),
+		handler: NewPodHandler(config, HandlerOptions{
+			Broker:           broker,
+			StuckAfter:       *stuckAfter,
+			Usage:            NewUsageLedger(*teamLabel, *usageRetention),
+			UsageStore:       usageStore,
+			Nodes:            nodeInformer.GetStore(),
+			NodePodThreshold: *nodePodThreshold,
+			Jobs:             jobs,
+			CronJobs:         cronJobs,
+			Rollouts:         NewRolloutTracker(*rolloutStallAfter, workloadInformers["ReplicaSet"].GetStore(), workloadInformers["StatefulSet"].GetStore()),
+			Replicas:         NewReplicaTracker(workloads...),
+			Events:           eventInformer.GetIndexer(),
+			Recorder:         NewEventRecorder(client),
+			NetworkPolicies:  networkPolicies,
+			References:       references,
+			Services:         serviceInformer.GetStore(),
+			Endpoints:        endpointsInformer.GetStore(),
+			Owners:           owners,
+			Quotas:           quotaInformer.GetStore(),
+			Forecaster:       NewQuotaForecaster(*quotaWindow, *quotaHorizon),
+			Pods:             informer.GetStore(),
+			History:          NewCountHistory(*historyMinutes, *historyHours),
+			Churn:            NewChurnDetector(*churnDeviation, *churnMinCreations, jobInformer.GetStore()),
+			Synced:           synced,
+		}),
+	}
+
+	eventInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: controller.handler.EventObserved,
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			controller.handler.EventObserved(newObj)
+		},
+		DeleteFunc: controller.handler.EventDeleted,
+	})
+
+	// serve the read-only query API from the informer and handler state
+	if *listenAddr != "" {
+		go NewAPIServer(informer, controller.handler).ListenAndServe(*listenAddr)
 	}
 
 	// stopCh channel is to synchronize graceful shutdown
@@ -126,4 +278,7 @@ func main() {
 	signal.Notify(sigTerm, syscall.SIGTERM)
 	signal.Notify(sigTerm, syscall.SIGINT)
 	<-sigTerm
+
+	// save the usage accounted since the last periodic save
+	controller.handler.PersistUsage()
 }
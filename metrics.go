@@ -0,0 +1,335 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jayapriya90/k8s-pod-monitor/v1alpha1"
+	core_v1 "k8s.io/api/core/v1"
+)
+
+// metricsWriter writes metrics in the Prometheus text exposition format.
+// Samples of the same metric have to be written one after the other
+type metricsWriter struct {
+	w    io.Writer
+	last string
+}
+
+// gauge writes a gauge sample. labels are name, value pairs
+func (m *metricsWriter) gauge(name, help string, value float64, labels ...string) {
+	m.sample("gauge", name, help, value, labels...)
+}
+
+// counter writes a counter sample. labels are name, value pairs
+func (m *metricsWriter) counter(name, help string, value float64, labels ...string) {
+	m.sample("counter", name, help, value, labels...)
+}
+
+// sample writes a sample, preceded by the HELP and TYPE lines on the first
+// sample of the metric
+func (m *metricsWriter) sample(kind, name, help string, value float64, labels ...string) {
+	if name != m.last {
+		fmt.Fprintf(m.w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+		m.last = name
+	}
+	fmt.Fprint(m.w, name)
+	if len(labels) > 0 {
+		pairs := make([]string, 0, len(labels)/2)
+		for i := 0; i+1 < len(labels); i += 2 {
+			pairs = append(pairs, labels[i]+"="+strconv.Quote(labels[i+1]))
+		}
+		fmt.Fprintf(m.w, "{%s}", strings.Join(pairs, ","))
+	}
+	fmt.Fprintf(m.w, " %s\n", strconv.FormatFloat(value, 'g', -1, 64))
+}
+
+// handleMetrics serves /metrics from the status last written to the pod-monitor resource
+func (s *APIServer) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	var buf bytes.Buffer
+	writeStatusMetrics(&metricsWriter{w: &buf}, s.handler.Status(), time.Now())
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	w.Write(buf.Bytes())
+}
+
+// writeStatusMetrics writes the metrics of a status. Durations that are not
+// part of the status are measured up to now
+func writeStatusMetrics(m *metricsWriter, status v1alpha1.PodMonitorStatus, now time.Time) {
+	m.gauge("podmonitor_pods_created", "Pods created since the monitor started.", float64(status.PodCreatedCount))
+	m.gauge("podmonitor_pods_running", "Pods currently running.", float64(status.PodRunningCount))
+	for _, ns := range status.Namespaces {
+		m.gauge("podmonitor_namespace_pods_created", "Pods created since the monitor started per namespace.", float64(ns.PodCreatedCount), "namespace", ns.Namespace)
+	}
+	for _, ns := range status.Namespaces {
+		for _, phase := range []struct {
+			phase core_v1.PodPhase
+			count int32
+		}{{core_v1.PodRunning, ns.PodRunningCount}, {core_v1.PodPending, ns.PodPendingCount}, {core_v1.PodFailed, ns.PodFailedCount}} {
+			m.gauge("podmonitor_namespace_pods", "Current pods per namespace and phase.", float64(phase.count), "namespace", ns.Namespace, "phase", string(phase.phase))
+		}
+	}
+
+	if status.Resources != nil {
+		writeResourceMetrics(m, "namespace", status.Resources.Namespaces)
+		writeResourceMetrics(m, "owner", status.Resources.Owners)
+		writeResourceMetrics(m, "qos_class", status.Resources.QOSClasses)
+	}
+
+	if status.Nodes != nil {
+		writeNodeMetrics(m, status.Nodes)
+	}
+	if status.Topology != nil {
+		writeTopologyMetrics(m, status.Topology)
+	}
+	if status.Restarts != nil {
+		writeRestartMetrics(m, status.Restarts)
+	}
+	if status.Events != nil {
+		for _, ns := range status.Events.Namespaces {
+			for _, reason := range ns.Reasons {
+				m.counter("podmonitor_pod_events_total", "Occurrences of events of tracked pods per namespace, type and reason.", float64(reason.Count), "namespace", ns.Namespace, "type", reason.Type, "reason", reason.Reason)
+			}
+		}
+	}
+	if status.Jobs != nil {
+		writeJobMetrics(m, "job", status.Jobs.Jobs)
+		writeJobMetrics(m, "cronjob", status.Jobs.CronJobs)
+	}
+	writeRolloutMetrics(m, status.Rollouts, now)
+	for _, shortfall := range status.Shortfalls {
+		m.gauge("podmonitor_owner_replica_shortfall", "Desired replicas minus ready pods of workloads that are short.", float64(shortfall.Desired-shortfall.Ready), "owner", shortfall.Owner)
+	}
+	for _, shortfall := range status.Shortfalls {
+		m.gauge("podmonitor_owner_replica_shortfall_seconds", "Time workloads have had fewer ready pods than desired.", now.Sub(shortfall.Since.Time).Seconds(), "owner", shortfall.Owner)
+	}
+	writeSecurityMetrics(m, status.Security)
+	for _, ns := range status.NetworkCoverage {
+		for _, direction := range []struct {
+			direction string
+			pods      int32
+		}{{"ingress", ns.UnprotectedIngress}, {"egress", ns.UnprotectedEgress}} {
+			m.gauge("podmonitor_network_policy_unprotected_pods", "Running pods not selected by any NetworkPolicy per namespace and direction.", float64(direction.pods), "namespace", ns.Namespace, "direction", direction.direction)
+		}
+	}
+	for _, ns := range status.MissingReferences {
+		for _, ref := range ns.Missing {
+			m.gauge("podmonitor_missing_reference_blocked_pods", "Waiting pods referencing an object that does not exist per namespace and object.", float64(ref.Pods), "namespace", ns.Namespace, "kind", ref.Kind, "name", ref.Name)
+		}
+	}
+	if status.Services != nil {
+		writeServiceMetrics(m, status.Services)
+	}
+	for _, ns := range status.Unowned {
+		m.gauge("podmonitor_unowned_pods", "Running and pending pods without controller per namespace and reason.", float64(ns.Naked), "namespace", ns.Namespace, "reason", unownedNaked)
+		m.gauge("podmonitor_unowned_pods", "Running and pending pods without controller per namespace and reason.", float64(ns.Orphaned), "namespace", ns.Namespace, "reason", unownedOrphaned)
+	}
+	for _, anomaly := range status.Churn {
+		m.gauge("podmonitor_churn_pod_creations", "Pods created in the last minute by owners spiking above their baseline.", float64(anomaly.Creations), "namespace", anomaly.Namespace, "owner", anomaly.Owner)
+	}
+	for _, quota := range status.Quotas {
+		m.gauge("podmonitor_quota_used_percent", "Usage of the pods and requests.cpu quotas per namespace.", float64(quota.UsedPercent), "namespace", quota.Namespace, "resource", quota.Resource)
+	}
+	for _, quota := range status.Quotas {
+		if quota.ExhaustedAt != nil {
+			m.gauge("podmonitor_quota_exhaustion_timestamp_seconds", "Time quotas ran or are forecast to run out at the current growth.", float64(quota.ExhaustedAt.Unix()), "namespace", quota.Namespace, "resource", quota.Resource)
+		}
+	}
+	if status.Images != nil {
+		m.gauge("podmonitor_noncompliant_image_pods", "Running pods using images the spec does not allow.", float64(status.Images.NonCompliantPods))
+	}
+	if status.Policy != nil {
+		for _, ns := range status.Policy.Namespaces {
+			rules := make([]string, 0, len(ns.Rules))
+			for rule := range ns.Rules {
+				rules = append(rules, rule)
+			}
+			sort.Strings(rules)
+			for _, rule := range rules {
+				m.gauge("podmonitor_policy_violating_pods", "Running and pending pods failing a policy rule per namespace.", float64(ns.Rules[rule]), "namespace", ns.Name, "rule", rule)
+			}
+		}
+	}
+	if status.Scheduling != nil {
+		for _, ns := range status.Scheduling.Namespaces {
+			for _, reason := range ns.Reasons {
+				m.gauge("podmonitor_unschedulable_pods", "Pending pods the scheduler could not place per namespace and reason.", float64(reason.Pods), "namespace", ns.Namespace, "reason", reason.Reason)
+			}
+		}
+	}
+
+	for _, condition := range status.Conditions {
+		value := 0.0
+		if condition.Status == "True" {
+			value = 1
+		}
+		m.gauge("podmonitor_condition", "Status of the pod-monitor conditions, 1 when active.", value, "type", condition.Type)
+	}
+}
+
+// writeResourceMetrics writes the summed requests and limits of running pods per breakdown
+func writeResourceMetrics(m *metricsWriter, label string, breakdown []v1alpha1.ResourceBreakdown) {
+	for _, kind := range []string{"requests", "limits"} {
+		name := "podmonitor_running_pod_resource_" + kind + "_by_" + label
+		help := fmt.Sprintf("Sum of the resource %s of running pods per %s, in cores for cpu and bytes otherwise.", kind, strings.Replace(label, "_", " ", -1))
+		for _, entry := range breakdown {
+			resources := entry.Requests
+			if kind == "limits" {
+				resources = entry.Limits
+			}
+			names := make([]string, 0, len(resources))
+			for resource := range resources {
+				names = append(names, string(resource))
+			}
+			sort.Strings(names)
+			for _, resource := range names {
+				m.gauge(name, help, quantityValue(resources[core_v1.ResourceName(resource)]), label, entry.Name, "resource", resource)
+			}
+		}
+	}
+	for _, entry := range breakdown {
+		m.gauge("podmonitor_running_pods_by_"+label, fmt.Sprintf("Running pods per %s.", strings.Replace(label, "_", " ", -1)), float64(entry.Pods), label, entry.Name)
+	}
+}
+
+func writeNodeMetrics(m *metricsWriter, nodes *v1alpha1.NodesStatus) {
+	m.gauge("podmonitor_cluster_pod_utilization_ratio", "Running pods on ready nodes over their allocatable pods.", float64(nodes.UtilizationPercent)/100)
+	m.gauge("podmonitor_running_pods_on_not_ready_nodes", "Running pods bound to nodes that are not ready or gone.", float64(nodes.RunningPodsOnNotReadyNodes))
+	for _, node := range nodes.Nodes {
+		m.gauge("podmonitor_node_running_pods", "Running pods per node.", float64(node.RunningPods), "node", node.Name)
+	}
+	for _, node := range nodes.Nodes {
+		m.gauge("podmonitor_node_allocatable_pods", "Allocatable pods per node.", float64(node.AllocatablePods), "node", node.Name)
+	}
+	for _, node := range nodes.Nodes {
+		ready := 0.0
+		if node.Ready {
+			ready = 1
+		}
+		m.gauge("podmonitor_node_ready", "Whether the node is ready, 1 when ready.", ready, "node", node.Name)
+	}
+}
+
+func writeTopologyMetrics(m *metricsWriter, topology *v1alpha1.TopologyStatus) {
+	for _, owner := range topology.Owners {
+		m.gauge("podmonitor_owner_replica_nodes", "Nodes the running replicas of an owner are spread over.", float64(owner.Nodes), "owner", owner.Owner)
+	}
+	for _, owner := range topology.Owners {
+		for _, zone := range owner.Zones {
+			m.gauge("podmonitor_owner_replicas_by_zone", "Running replicas of an owner per zone.", float64(zone.Replicas), "owner", owner.Owner, "zone", zone.Zone)
+		}
+	}
+	for _, owner := range topology.Owners {
+		for _, risk := range []struct {
+			reason string
+			active bool
+		}{{"single_node", owner.SingleNode}, {"single_zone", owner.SingleZone}} {
+			value := 0.0
+			if risk.active {
+				value = 1
+			}
+			m.gauge("podmonitor_owner_availability_risk", "1 while all running replicas of an owner share a node or zone.", value, "owner", owner.Owner, "reason", risk.reason)
+		}
+	}
+}
+
+func writeRestartMetrics(m *metricsWriter, restarts *v1alpha1.RestartStatus) {
+	for _, ns := range restarts.Namespaces {
+		m.counter("podmonitor_container_restarts_total", "Container restarts since the monitor started per namespace.", float64(ns.Restarts), "namespace", ns.Name)
+	}
+	for _, ns := range restarts.Namespaces {
+		m.counter("podmonitor_container_oomkilled_total", "Containers terminated as OOMKilled since the monitor started per namespace.", float64(ns.Terminations[reasonOOMKilled]), "namespace", ns.Name)
+	}
+	for _, ns := range restarts.Namespaces {
+		reasons := make([]string, 0, len(ns.Terminations))
+		for reason := range ns.Terminations {
+			reasons = append(reasons, reason)
+		}
+		sort.Strings(reasons)
+		for _, reason := range reasons {
+			m.counter("podmonitor_container_terminations_total", "Container terminations since the monitor started per namespace and reason.", float64(ns.Terminations[reason]), "namespace", ns.Name, "reason", reason)
+		}
+	}
+	for _, owner := range restarts.TopOwners {
+		m.gauge("podmonitor_top_owner_container_restarts", "Container restarts of the owners with the most restarts.", float64(owner.Restarts), "owner", owner.Name)
+	}
+}
+
+// writeJobMetrics writes the outcomes of the runs within the job window per Job or CronJob
+func writeJobMetrics(m *metricsWriter, label string, outcomes []v1alpha1.JobOutcome) {
+	for _, outcome := range outcomes {
+		for _, result := range []struct {
+			result string
+			runs   int32
+		}{{"succeeded", outcome.Succeeded}, {"failed", outcome.Failed}} {
+			m.gauge("podmonitor_"+label+"_runs", "Runs finished within the job window per "+label+" and result.", float64(result.runs), label, outcome.Name, "result", result.result)
+		}
+	}
+	for _, outcome := range outcomes {
+		m.gauge("podmonitor_"+label+"_average_duration_seconds", "Average duration of the runs finished within the job window per "+label+".", float64(outcome.AverageDurationSeconds), label, outcome.Name)
+	}
+	for _, outcome := range outcomes {
+		m.gauge("podmonitor_"+label+"_retries", "Failed pods of the runs finished within the job window per "+label+".", float64(outcome.Retries), label, outcome.Name)
+	}
+}
+
+func writeRolloutMetrics(m *metricsWriter, rollouts []v1alpha1.RolloutStatus, now time.Time) {
+	for _, rollout := range rollouts {
+		duration := float64(rollout.DurationSeconds)
+		if rollout.Completed == nil {
+			duration = now.Sub(rollout.Started.Time).Seconds()
+		}
+		m.gauge("podmonitor_rollout_duration_seconds", "Duration of the rollouts in progress or recently completed.", duration, "workload", rollout.Workload, "revision", rollout.Revision)
+	}
+	for _, rollout := range rollouts {
+		for _, revision := range []struct {
+			revision string
+			health   v1alpha1.RevisionHealth
+		}{{"new", rollout.New}, {"previous", rollout.Previous}} {
+			m.gauge("podmonitor_rollout_crashing_pods", "Crashing pods of the new and previous revision of a rollout.", float64(revision.health.CrashingPods), "workload", rollout.Workload, "revision", revision.revision)
+		}
+	}
+	for _, rollout := range rollouts {
+		for _, revision := range []struct {
+			revision string
+			health   v1alpha1.RevisionHealth
+		}{{"new", rollout.New}, {"previous", rollout.Previous}} {
+			m.gauge("podmonitor_rollout_container_restarts", "Container restarts of the current pods of the new and previous revision of a rollout.", float64(revision.health.Restarts), "workload", rollout.Workload, "revision", revision.revision)
+		}
+	}
+}
+
+func writeSecurityMetrics(m *metricsWriter, namespaces []v1alpha1.NamespaceSecurity) {
+	for _, ns := range namespaces {
+		for _, level := range []struct {
+			level      string
+			violations int32
+		}{{levelBaseline, ns.BaselineViolations}, {levelRestricted, ns.RestrictedViolations}} {
+			m.gauge("podmonitor_pod_security_violating_pods", "Running and pending pods violating a Pod Security Standards level per namespace.", float64(level.violations), "namespace", ns.Namespace, "level", level.level)
+		}
+	}
+	for _, ns := range namespaces {
+		for _, check := range ns.Checks {
+			m.gauge("podmonitor_pod_security_failed_check_pods", "Running and pending pods failing a Pod Security Standards check per namespace.", float64(check.Pods), "namespace", ns.Namespace, "check", check.Check, "level", check.Level)
+		}
+	}
+}
+
+// writeServiceMetrics writes the not ready pods per Service and the Services
+// without running pods. Not ready endpoints are sorted by namespace and Service
+func writeServiceMetrics(m *metricsWriter, services *v1alpha1.ServicesStatus) {
+	for i := 0; i < len(services.NotReady); {
+		first, pods := services.NotReady[i], 0
+		for ; i < len(services.NotReady) && services.NotReady[i].Namespace == first.Namespace && services.NotReady[i].Service == first.Service; i++ {
+			pods++
+		}
+		m.gauge("podmonitor_service_not_ready_pods", "Running pods listed as not ready addresses of a Service.", float64(pods), "namespace", first.Namespace, "service", first.Service)
+	}
+	for _, service := range services.Unmatched {
+		m.gauge("podmonitor_service_without_running_pods", "Services whose selector matches no running pod.", 1, "namespace", service.Namespace, "service", service.Name)
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"sort"
+
+	"github.com/jayapriya90/k8s-pod-monitor/v1alpha1"
+	core_v1 "k8s.io/api/core/v1"
+	networking_v1 "k8s.io/api/networking/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// UnprotectedPod is a running pod not selected by any NetworkPolicy for
+// ingress, egress or both, returned by /api/v1/network-coverage
+type UnprotectedPod struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Owner     string `json:"owner,omitempty"`
+	Ingress   bool   `json:"ingress"`
+	Egress    bool   `json:"egress"`
+}
+
+// policyDirections returns whether a NetworkPolicy applies to ingress and
+// egress. Without policyTypes a policy always covers ingress, and egress when
+// it has egress rules
+func policyDirections(policy *networking_v1.NetworkPolicy) (bool, bool) {
+	if len(policy.Spec.PolicyTypes) == 0 {
+		return true, len(policy.Spec.Egress) > 0
+	}
+	var ingress, egress bool
+	for _, policyType := range policy.Spec.PolicyTypes {
+		switch policyType {
+		case networking_v1.PolicyTypeIngress:
+			ingress = true
+		case networking_v1.PolicyTypeEgress:
+			egress = true
+		}
+	}
+	return ingress, egress
+}
+
+// networkCoverage finds the running pods not selected by any NetworkPolicy of
+// their namespace, per direction. policies is the content of the NetworkPolicy cache
+func networkCoverage(pods []*core_v1.Pod, policies []interface{}) ([]v1alpha1.NamespaceNetworkCoverage, []UnprotectedPod) {
+	type selector struct {
+		selector        labels.Selector
+		ingress, egress bool
+	}
+	selectors := make(map[string][]selector)
+	for _, obj := range policies {
+		policy, ok := obj.(*networking_v1.NetworkPolicy)
+		if !ok {
+			continue
+		}
+		parsed, err := meta_v1.LabelSelectorAsSelector(&policy.Spec.PodSelector)
+		if err != nil {
+			continue
+		}
+		ingress, egress := policyDirections(policy)
+		selectors[policy.Namespace] = append(selectors[policy.Namespace], selector{parsed, ingress, egress})
+	}
+
+	namespaces := make(map[string]*v1alpha1.NamespaceNetworkCoverage)
+	unprotected := []UnprotectedPod{}
+	for _, pod := range pods {
+		if pod.Status.Phase != core_v1.PodRunning || pod.Spec.HostNetwork {
+			continue
+		}
+		var ingress, egress bool
+		podLabels := labels.Set(pod.Labels)
+		for _, s := range selectors[pod.Namespace] {
+			if s.selector.Matches(podLabels) {
+				ingress, egress = ingress || s.ingress, egress || s.egress
+			}
+		}
+		ns, exists := namespaces[pod.Namespace]
+		if !exists {
+			ns = &v1alpha1.NamespaceNetworkCoverage{Namespace: pod.Namespace}
+			namespaces[pod.Namespace] = ns
+		}
+		ns.RunningPods++
+		if !ingress {
+			ns.UnprotectedIngress++
+		}
+		if !egress {
+			ns.UnprotectedEgress++
+		}
+		if !ingress || !egress {
+			unprotected = append(unprotected, UnprotectedPod{Namespace: pod.Namespace, Name: pod.Name, Owner: podOwner(pod), Ingress: !ingress, Egress: !egress})
+		}
+	}
+
+	coverage := make([]v1alpha1.NamespaceNetworkCoverage, 0, len(namespaces))
+	for _, ns := range namespaces {
+		coverage = append(coverage, *ns)
+	}
+	sort.Slice(coverage, func(i, j int) bool {
+		return coverage[i].Namespace < coverage[j].Namespace
+	})
+	sort.Slice(unprotected, func(i, j int) bool {
+		a, b := unprotected[i], unprotected[j]
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return a.Name < b.Name
+	})
+	return coverage, unprotected
+}
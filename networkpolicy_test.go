@@ -0,0 +1,43 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jayapriya90/k8s-pod-monitor/v1alpha1"
+	"github.com/stretchr/testify/require"
+	core_v1 "k8s.io/api/core/v1"
+	networking_v1 "k8s.io/api/networking/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestNetworkCoverage(t *testing.T) {
+	ingressOnly := &networking_v1.NetworkPolicy{
+		ObjectMeta: meta_v1.ObjectMeta{Namespace: "web", Name: "frontend"},
+		Spec: networking_v1.NetworkPolicySpec{
+			PodSelector: meta_v1.LabelSelector{MatchLabels: map[string]string{"app": "frontend"}},
+		},
+	}
+	egressAll := &networking_v1.NetworkPolicy{
+		ObjectMeta: meta_v1.ObjectMeta{Namespace: "web", Name: "deny-egress"},
+		Spec: networking_v1.NetworkPolicySpec{
+			PolicyTypes: []networking_v1.PolicyType{networking_v1.PolicyTypeEgress},
+		},
+	}
+	frontend := newTestPod("web", "a", core_v1.PodRunning, time.Now())
+	frontend.Labels = map[string]string{"app": "frontend"}
+	backend := newTestPod("web", "b", core_v1.PodRunning, time.Now())
+	other := newTestPod("batch", "c", core_v1.PodRunning, time.Now())
+	pending := newTestPod("batch", "d", core_v1.PodPending, time.Now())
+
+	coverage, unprotected := networkCoverage([]*core_v1.Pod{&frontend, &backend, &other, &pending}, []interface{}{ingressOnly, egressAll})
+	require.Equal(t, []v1alpha1.NamespaceNetworkCoverage{
+		{Namespace: "batch", RunningPods: 1, UnprotectedIngress: 1, UnprotectedEgress: 1},
+		{Namespace: "web", RunningPods: 2, UnprotectedIngress: 1, UnprotectedEgress: 0},
+	}, coverage)
+	require.Len(t, unprotected, 2)
+	require.Equal(t, "c", unprotected[0].Name)
+	require.Equal(t, "b", unprotected[1].Name)
+	require.True(t, unprotected[1].Ingress)
+	require.False(t, unprotected[1].Egress)
+}
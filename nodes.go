@@ -0,0 +1,73 @@
+package main
+
+import (
+	"sort"
+
+	"github.com/jayapriya90/k8s-pod-monitor/v1alpha1"
+	core_v1 "k8s.io/api/core/v1"
+)
+
+// nodesStatus matches the running pods against the nodes they are bound to.
+// Utilization only covers ready nodes, as pods cannot be scheduled onto the
+// others. Pods on nodes that are not ready or no longer exist are counted
+// separately
+func nodesStatus(pods []*core_v1.Pod, nodes []interface{}, threshold float64) *v1alpha1.NodesStatus {
+	running := make(map[string]int32)
+	for _, pod := range pods {
+		running[pod.Spec.NodeName]++
+	}
+
+	status := &v1alpha1.NodesStatus{}
+	known := make(map[string]bool, len(nodes))
+	for _, obj := range nodes {
+		node, ok := obj.(*core_v1.Node)
+		if !ok {
+			continue
+		}
+		known[node.Name] = true
+		allocatable := node.Status.Allocatable[core_v1.ResourcePods]
+		entry := v1alpha1.NodeStatus{
+			Name:            node.Name,
+			Ready:           isNodeReady(node),
+			RunningPods:     running[node.Name],
+			AllocatablePods: int32(allocatable.Value()),
+		}
+		entry.UtilizationPercent = utilizationPercent(entry.RunningPods, entry.AllocatablePods)
+		entry.NearPodLimit = entry.AllocatablePods > 0 && float64(entry.RunningPods) >= threshold*float64(entry.AllocatablePods)
+		if entry.Ready {
+			status.RunningPods += entry.RunningPods
+			status.AllocatablePods += entry.AllocatablePods
+		} else {
+			status.NotReadyNodes++
+			status.RunningPodsOnNotReadyNodes += entry.RunningPods
+		}
+		status.Nodes = append(status.Nodes, entry)
+	}
+	for name, count := range running {
+		if !known[name] {
+			status.RunningPodsOnNotReadyNodes += count
+		}
+	}
+	status.UtilizationPercent = utilizationPercent(status.RunningPods, status.AllocatablePods)
+	sort.Slice(status.Nodes, func(i, j int) bool {
+		return status.Nodes[i].Name < status.Nodes[j].Name
+	})
+	return status
+}
+
+// isNodeReady reports whether the NodeReady condition of the node is true
+func isNodeReady(node *core_v1.Node) bool {
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == core_v1.NodeReady {
+			return condition.Status == core_v1.ConditionTrue
+		}
+	}
+	return false
+}
+
+func utilizationPercent(used, capacity int32) int32 {
+	if capacity == 0 {
+		return 0
+	}
+	return used * 100 / capacity
+}
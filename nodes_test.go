@@ -0,0 +1,42 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	core_v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestNodesStatus(t *testing.T) {
+	node := func(name string, pods int64, ready core_v1.ConditionStatus) *core_v1.Node {
+		return &core_v1.Node{
+			ObjectMeta: meta_v1.ObjectMeta{Name: name},
+			Status: core_v1.NodeStatus{
+				Allocatable: core_v1.ResourceList{core_v1.ResourcePods: *resource.NewQuantity(pods, resource.DecimalSI)},
+				Conditions:  []core_v1.NodeCondition{{Type: core_v1.NodeReady, Status: ready}},
+			},
+		}
+	}
+	var pods []*core_v1.Pod
+	for i, nodeName := range []string{"a", "a", "a", "b", "c", "gone"} {
+		pod := newTestPod("default", string(rune('p'+i)), core_v1.PodRunning, time.Now())
+		pod.Spec.NodeName = nodeName
+		pods = append(pods, &pod)
+	}
+
+	status := nodesStatus(pods, []interface{}{node("a", 3, core_v1.ConditionTrue), node("b", 5, core_v1.ConditionTrue), node("c", 5, core_v1.ConditionFalse)}, 0.9)
+	require.Equal(t, int32(4), status.RunningPods)
+	require.Equal(t, int32(8), status.AllocatablePods)
+	require.Equal(t, int32(50), status.UtilizationPercent)
+	require.Equal(t, int32(1), status.NotReadyNodes)
+	require.Equal(t, int32(2), status.RunningPodsOnNotReadyNodes)
+	require.Len(t, status.Nodes, 3)
+	require.True(t, status.Nodes[0].NearPodLimit)
+	require.False(t, status.Nodes[1].NearPodLimit)
+
+	condition := nodesNearPodLimitCondition(status, 0.9)
+	require.Equal(t, meta_v1.ConditionTrue, condition.Status)
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/jayapriya90/k8s-pod-monitor/v1alpha1"
+	core_v1 "k8s.io/api/core/v1"
+)
+
+// policy rule names, required labels are reported as requiredLabel/<label>
+const (
+	ruleRequiredLabel  = "requiredLabel"
+	ruleMemoryLimits   = "memoryLimits"
+	ruleCPULimits      = "cpuLimits"
+	ruleReadinessProbe = "readinessProbe"
+	ruleLatestTag      = "latestTag"
+)
+
+// PolicyViolation is a pod failing rules of the policy, returned by /api/v1/policy
+type PolicyViolation struct {
+	Namespace string   `json:"namespace"`
+	Name      string   `json:"name"`
+	Owner     string   `json:"owner,omitempty"`
+	Rules     []string `json:"rules"`
+}
+
+// isLatestImage reports whether an image is tagged latest or has neither tag nor digest
+func isLatestImage(image string) bool {
+	if strings.Contains(image, "@") {
+		return false
+	}
+	name := image[strings.LastIndex(image, "/")+1:]
+	i := strings.LastIndex(name, ":")
+	return i < 0 || name[i+1:] == "latest"
+}
+
+// evaluatePolicy returns the rules of the policy a pod fails
+func evaluatePolicy(policy *v1alpha1.PolicySpec, pod *core_v1.Pod) []string {
+	var failed []string
+	for _, label := range policy.RequiredLabels {
+		if _, exists := pod.Labels[label]; !exists {
+			failed = append(failed, ruleRequiredLabel+"/"+label)
+		}
+	}
+	rules := make(map[string]bool)
+	for _, container := range podContainers(pod) {
+		if _, exists := container.Resources.Limits[core_v1.ResourceMemory]; policy.RequireMemoryLimits && !exists {
+			rules[ruleMemoryLimits] = true
+		}
+		if _, exists := container.Resources.Limits[core_v1.ResourceCPU]; policy.RequireCPULimits && !exists {
+			rules[ruleCPULimits] = true
+		}
+		if policy.DisallowLatestTag && isLatestImage(container.Image) {
+			rules[ruleLatestTag] = true
+		}
+	}
+	// init containers run to completion and have no probes
+	for _, container := range pod.Spec.Containers {
+		if policy.RequireReadinessProbe && container.ReadinessProbe == nil {
+			rules[ruleReadinessProbe] = true
+		}
+	}
+	for _, rule := range []string{ruleMemoryLimits, ruleCPULimits, ruleReadinessProbe, ruleLatestTag} {
+		if rules[rule] {
+			failed = append(failed, rule)
+		}
+	}
+	return failed
+}
+
+// policyViolations evaluates the running and pending pods against the policy,
+// sorted by namespace and name
+func policyViolations(policy *v1alpha1.PolicySpec, pods []*core_v1.Pod) []PolicyViolation {
+	violations := []PolicyViolation{}
+	if policy == nil {
+		return violations
+	}
+	excluded := make(map[string]bool, len(policy.ExcludedNamespaces))
+	for _, namespace := range policy.ExcludedNamespaces {
+		excluded[namespace] = true
+	}
+	for _, pod := range pods {
+		if excluded[pod.Namespace] || (pod.Status.Phase != core_v1.PodRunning && pod.Status.Phase != core_v1.PodPending) {
+			continue
+		}
+		if rules := evaluatePolicy(policy, pod); len(rules) > 0 {
+			violations = append(violations, PolicyViolation{Namespace: pod.Namespace, Name: pod.Name, Owner: podOwner(pod), Rules: rules})
+		}
+	}
+	sort.Slice(violations, func(i, j int) bool {
+		a, b := violations[i], violations[j]
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return a.Name < b.Name
+	})
+	return violations
+}
+
+// policyAccumulator counts violating pods and failed rules per name
+type policyAccumulator map[string]*v1alpha1.PolicyBreakdown
+
+func (a policyAccumulator) add(name string, rules []string) {
+	breakdown, exists := a[name]
+	if !exists {
+		breakdown = &v1alpha1.PolicyBreakdown{Name: name, Rules: make(map[string]int32)}
+		a[name] = breakdown
+	}
+	breakdown.Pods++
+	for _, rule := range rules {
+		breakdown.Rules[rule]++
+	}
+}
+
+func (a policyAccumulator) breakdown() []v1alpha1.PolicyBreakdown {
+	breakdown := make([]v1alpha1.PolicyBreakdown, 0, len(a))
+	for _, entry := range a {
+		breakdown = append(breakdown, *entry)
+	}
+	sort.Slice(breakdown, func(i, j int) bool {
+		return breakdown[i].Name < breakdown[j].Name
+	})
+	return breakdown
+}
+
+// policyStatus summarizes violations per namespace and owner
+func policyStatus(violations []PolicyViolation) *v1alpha1.PolicyStatus {
+	namespaces, owners := policyAccumulator{}, policyAccumulator{}
+	for _, violation := range violations {
+		namespaces.add(violation.Namespace, violation.Rules)
+		owner := violation.Owner
+		if owner == "" {
+			owner = noOwner
+		}
+		owners.add(violation.Namespace+"/"+owner, violation.Rules)
+	}
+	return &v1alpha1.PolicyStatus{
+		ViolatingPods: int32(len(violations)),
+		Namespaces:    namespaces.breakdown(),
+		Owners:        owners.breakdown(),
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jayapriya90/k8s-pod-monitor/v1alpha1"
+	"github.com/stretchr/testify/require"
+	core_v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestPolicyViolations(t *testing.T) {
+	policy := &v1alpha1.PolicySpec{
+		RequiredLabels:        []string{"team", "app"},
+		RequireMemoryLimits:   true,
+		RequireReadinessProbe: true,
+		DisallowLatestTag:     true,
+		ExcludedNamespaces:    []string{"kube-system"},
+	}
+	compliant := newTestPod("web", "a", core_v1.PodRunning, time.Now())
+	compliant.Labels = map[string]string{"team": "payments", "app": "frontend"}
+	compliant.Spec.Containers = []core_v1.Container{{
+		Image:          "registry.local:5000/frontend:1.2",
+		ReadinessProbe: &core_v1.Probe{},
+		Resources:      core_v1.ResourceRequirements{Limits: core_v1.ResourceList{core_v1.ResourceMemory: resource.MustParse("64Mi")}},
+	}}
+	violating := newTestPod("web", "b", core_v1.PodRunning, time.Now())
+	violating.Labels = map[string]string{"team": "payments"}
+	violating.Spec.Containers = []core_v1.Container{{Image: "registry.local:5000/frontend"}}
+	excluded := newTestPod("kube-system", "c", core_v1.PodRunning, time.Now())
+
+	violations := policyViolations(policy, []*core_v1.Pod{&compliant, &violating, &excluded})
+	require.Len(t, violations, 1)
+	require.Equal(t, "b", violations[0].Name)
+	require.Equal(t, []string{"requiredLabel/app", "memoryLimits", "readinessProbe", "latestTag"}, violations[0].Rules)
+
+	status := policyStatus(violations)
+	require.Equal(t, int32(1), status.ViolatingPods)
+	require.Equal(t, "web/"+noOwner, status.Owners[0].Name)
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jayapriya90/k8s-pod-monitor/v1alpha1"
+	core_v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// quotaBucket is the interval pod creations are counted in
+	quotaBucket = time.Minute
+	// quotaMinHistory is the history needed before a creation rate is trusted
+	quotaMinHistory = 10 * time.Minute
+)
+
+// quotaSample is the usage of the quota resources of a namespace, or the pods
+// created in it during a bucket and the CPU they request
+type quotaSample struct {
+	at       time.Time
+	pods     int64
+	cpuMilli int64
+}
+
+// value returns the pods, or the CPU requests in millicores for requests.cpu
+func (s quotaSample) value(name core_v1.ResourceName) int64 {
+	if name == core_v1.ResourceRequestsCPU {
+		return s.cpuMilli
+	}
+	return s.pods
+}
+
+// add counts a pod and its CPU requests in millicores
+func (s *quotaSample) add(pod *core_v1.Pod) {
+	requests, _ := podResources(pod)
+	s.pods++
+	if cpu, exists := requests[core_v1.ResourceCPU]; exists {
+		s.cpuMilli += cpu.MilliValue()
+	}
+}
+
+// quotaUsage returns the non-terminal pods of the pod cache and their CPU
+// requests in millicores per namespace, the way quotas account them
+func quotaUsage(pods []interface{}) map[string]quotaSample {
+	usage := make(map[string]quotaSample)
+	for _, obj := range pods {
+		pod, ok := obj.(*core_v1.Pod)
+		if !ok || pod.Status.Phase == core_v1.PodSucceeded || pod.Status.Phase == core_v1.PodFailed {
+			continue
+		}
+		sample := usage[pod.Namespace]
+		sample.add(pod)
+		usage[pod.Namespace] = sample
+	}
+	return usage
+}
+
+// quotaHard returns the tightest hard pod and CPU request limits per
+// namespace. Scoped quotas are skipped, they only apply to some pods
+func quotaHard(quotas []interface{}) map[string]map[core_v1.ResourceName]resource.Quantity {
+	hard := make(map[string]map[core_v1.ResourceName]resource.Quantity)
+	for _, obj := range quotas {
+		quota, ok := obj.(*core_v1.ResourceQuota)
+		if !ok || len(quota.Spec.Scopes) > 0 || quota.Spec.ScopeSelector != nil {
+			continue
+		}
+		for name, quantity := range quota.Spec.Hard {
+			if name == core_v1.ResourceCPU {
+				name = core_v1.ResourceRequestsCPU
+			}
+			if name != core_v1.ResourcePods && name != core_v1.ResourceRequestsCPU {
+				continue
+			}
+			if hard[quota.Namespace] == nil {
+				hard[quota.Namespace] = make(map[core_v1.ResourceName]resource.Quantity)
+			}
+			if current, exists := hard[quota.Namespace][name]; !exists || quantity.Cmp(current) < 0 {
+				hard[quota.Namespace][name] = quantity.DeepCopy()
+			}
+		}
+	}
+	return hard
+}
+
+// QuotaForecaster counts the pods created per namespace and forecasts when
+// their pod and CPU request quotas run out at the creation rate seen over a window
+type QuotaForecaster struct {
+	mu      sync.Mutex
+	window  time.Duration
+	horizon time.Duration
+	// started is when creations were first counted
+	started time.Time
+	// created holds the pods created per namespace in buckets, oldest first
+	created map[string][]quotaSample
+	// exhausted holds when each exhausted quota (`namespace/resource`) was first seen exhausted
+	exhausted map[string]time.Time
+}
+
+// NewQuotaForecaster returns a forecaster using the creation rate over window
+// and flagging namespaces forecast to run out within horizon
+func NewQuotaForecaster(window, horizon time.Duration) *QuotaForecaster {
+	return &QuotaForecaster{window: window, horizon: horizon, created: make(map[string][]quotaSample), exhausted: make(map[string]time.Time)}
+}
+
+// ObserveCreation counts a pod the tracker counted as created
+func (f *QuotaForecaster) ObserveCreation(pod *core_v1.Pod, now time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.started.IsZero() {
+		f.started = now
+	}
+	buckets := f.created[pod.Namespace]
+	at := now.Truncate(quotaBucket)
+	if n := len(buckets); n == 0 || buckets[n-1].at.Before(at) {
+		buckets = append(buckets, quotaSample{at: at})
+	}
+	buckets[len(buckets)-1].add(pod)
+	f.created[pod.Namespace] = buckets
+}
+
+// creationRate returns the pods created in a namespace within the window, the
+// CPU they request and the time they were counted over
+func (f *QuotaForecaster) creationRate(namespace string, now time.Time) (quotaSample, time.Duration) {
+	buckets := f.created[namespace]
+	for len(buckets) > 0 && now.Sub(buckets[0].at) > f.window {
+		buckets = buckets[1:]
+	}
+	f.created[namespace] = buckets
+	var created quotaSample
+	for _, bucket := range buckets {
+		created.pods += bucket.pods
+		created.cpuMilli += bucket.cpuMilli
+	}
+	elapsed := now.Sub(f.started)
+	if elapsed > f.window {
+		elapsed = f.window
+	}
+	return created, elapsed
+}
+
+// Update compares the usage of the pods of the pod cache with the hard limits
+// of the ResourceQuotas and returns the headroom of every quota with its forecast
+func (f *QuotaForecaster) Update(pods, quotas []interface{}, now time.Time) []v1alpha1.QuotaForecast {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.started.IsZero() {
+		f.started = now
+	}
+	usage := quotaUsage(pods)
+	hard := quotaHard(quotas)
+	forecasts := []v1alpha1.QuotaForecast{}
+	exhausted := make(map[string]bool)
+	for namespace, limits := range hard {
+		current := usage[namespace]
+		created, elapsed := f.creationRate(namespace, now)
+		for name, limit := range limits {
+			used, limitValue, quantity := current.value(name), limit.Value(), resource.NewQuantity
+			if name == core_v1.ResourceRequestsCPU {
+				limitValue, quantity = limit.MilliValue(), resource.NewMilliQuantity
+			}
+			if limitValue == 0 {
+				// a zero limit deliberately keeps the namespace empty, it is not running out
+				continue
+			}
+			forecast := v1alpha1.QuotaForecast{
+				Namespace: namespace,
+				Resource:  string(name),
+				Hard:      limit.DeepCopy(),
+				Used:      *quantity(used, resource.DecimalSI),
+			}
+			forecast.UsedPercent = int32(used * 100 / limitValue)
+			if used >= limitValue {
+				key := namespace + "/" + string(name)
+				since, exists := f.exhausted[key]
+				if !exists {
+					since = now
+					f.exhausted[key] = since
+				}
+				exhausted[key] = true
+				forecast.ExhaustedAt = &meta_v1.Time{Time: since}
+			} else if elapsed >= quotaMinHistory {
+				if rate := created.value(name); rate > 0 {
+					remaining := time.Duration(float64(limitValue-used) / float64(rate) * float64(elapsed))
+					forecast.ExhaustedAt = &meta_v1.Time{Time: now.Add(remaining).Truncate(time.Second)}
+				}
+			}
+			forecast.AtRisk = forecast.ExhaustedAt != nil && forecast.ExhaustedAt.Sub(now) <= f.horizon
+			forecasts = append(forecasts, forecast)
+		}
+	}
+	for namespace := range f.created {
+		if _, exists := hard[namespace]; !exists {
+			delete(f.created, namespace)
+		}
+	}
+	for key := range f.exhausted {
+		if !exhausted[key] {
+			delete(f.exhausted, key)
+		}
+	}
+	sort.Slice(forecasts, func(i, j int) bool {
+		a, b := forecasts[i], forecasts[j]
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return a.Resource < b.Resource
+	})
+	return forecasts
+}
+
+// quotaExhaustionCondition reports the namespaces forecast to run out of quota within horizon
+func quotaExhaustionCondition(forecasts []v1alpha1.QuotaForecast, horizon time.Duration) v1alpha1.PodMonitorCondition {
+	var atRisk []string
+	for _, forecast := range forecasts {
+		if forecast.AtRisk {
+			atRisk = append(atRisk, forecast.Namespace+"/"+forecast.Resource)
+		}
+	}
+	if len(atRisk) == 0 {
+		return v1alpha1.PodMonitorCondition{Type: conditionQuotaExhaustion, Status: meta_v1.ConditionFalse, Reason: "QuotaHeadroom"}
+	}
+	return v1alpha1.PodMonitorCondition{
+		Type:    conditionQuotaExhaustion,
+		Status:  meta_v1.ConditionTrue,
+		Reason:  "QuotaExhaustionForecast",
+		Message: fmt.Sprintf("%d quotas are exhausted or forecast to run out within %s: %s", len(atRisk), horizon, summarizeKeys(atRisk, 5)),
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	core_v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestQuotaForecaster(t *testing.T) {
+	now := time.Now()
+	quotas := []interface{}{
+		&core_v1.ResourceQuota{
+			ObjectMeta: meta_v1.ObjectMeta{Namespace: "web", Name: "compute"},
+			Spec:       core_v1.ResourceQuotaSpec{Hard: core_v1.ResourceList{core_v1.ResourcePods: resource.MustParse("10"), core_v1.ResourceCPU: resource.MustParse("4")}},
+		},
+		&core_v1.ResourceQuota{
+			ObjectMeta: meta_v1.ObjectMeta{Namespace: "web", Name: "best-effort"},
+			Spec:       core_v1.ResourceQuotaSpec{Hard: core_v1.ResourceList{core_v1.ResourcePods: resource.MustParse("1")}, Scopes: []core_v1.ResourceQuotaScope{core_v1.ResourceQuotaScopeBestEffort}},
+		},
+	}
+	pod := func(name string) *core_v1.Pod {
+		pod := newTestPod("web", name, core_v1.PodRunning, now)
+		pod.Spec.Containers = []core_v1.Container{{Resources: core_v1.ResourceRequirements{Requests: core_v1.ResourceList{core_v1.ResourceCPU: resource.MustParse("100m")}}}}
+		return &pod
+	}
+	forecaster := NewQuotaForecaster(time.Hour, 2*time.Hour)
+
+	forecasts := forecaster.Update([]interface{}{pod("a"), pod("b")}, quotas, now)
+	require.Len(t, forecasts, 2)
+	require.Equal(t, "pods", forecasts[0].Resource)
+	require.Equal(t, int32(20), forecasts[0].UsedPercent)
+	require.Nil(t, forecasts[0].ExhaustedAt)
+	require.Equal(t, "requests.cpu", forecasts[1].Resource)
+	require.Equal(t, "200m", forecasts[1].Used.String())
+
+	// two pods created in 30 minutes leave 6 pods of headroom, 90 minutes at that rate
+	forecaster.ObserveCreation(pod("c"), now.Add(10*time.Minute))
+	forecaster.ObserveCreation(pod("d"), now.Add(20*time.Minute))
+	later := now.Add(30 * time.Minute)
+	forecasts = forecaster.Update([]interface{}{pod("a"), pod("b"), pod("c"), pod("d")}, quotas, later)
+	require.Equal(t, later.Add(90*time.Minute).Truncate(time.Second), forecasts[0].ExhaustedAt.Time)
+	require.True(t, forecasts[0].AtRisk)
+	require.False(t, forecasts[1].AtRisk)
+	require.Equal(t, meta_v1.ConditionTrue, quotaExhaustionCondition(forecasts, 2*time.Hour).Status)
+
+	// an exhausted quota keeps the time it was first seen exhausted
+	full := []interface{}{}
+	for _, name := range []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j"} {
+		full = append(full, pod(name))
+	}
+	exhausted := now.Add(time.Hour)
+	forecasts = forecaster.Update(full, quotas, exhausted)
+	require.Equal(t, exhausted, forecasts[0].ExhaustedAt.Time)
+	forecasts = forecaster.Update(full, quotas, exhausted.Add(time.Minute))
+	require.Equal(t, exhausted, forecasts[0].ExhaustedAt.Time)
+}
+
+func TestQuotaForecasterSkipsZeroLimits(t *testing.T) {
+	quotas := []interface{}{&core_v1.ResourceQuota{
+		ObjectMeta: meta_v1.ObjectMeta{Namespace: "frozen", Name: "none"},
+		Spec:       core_v1.ResourceQuotaSpec{Hard: core_v1.ResourceList{core_v1.ResourcePods: resource.MustParse("0"), core_v1.ResourceCPU: resource.MustParse("1")}},
+	}}
+	forecasts := NewQuotaForecaster(time.Hour, 2*time.Hour).Update(nil, quotas, time.Now())
+	require.Len(t, forecasts, 1)
+	require.Equal(t, "requests.cpu", forecasts[0].Resource)
+	require.False(t, forecasts[0].AtRisk)
+}
+
+func TestQuotaForecasterForecastsFromCreations(t *testing.T) {
+	now := time.Now()
+	quotas := []interface{}{&core_v1.ResourceQuota{
+		ObjectMeta: meta_v1.ObjectMeta{Namespace: "batch", Name: "pods"},
+		Spec:       core_v1.ResourceQuotaSpec{Hard: core_v1.ResourceList{core_v1.ResourcePods: resource.MustParse("10")}},
+	}}
+	running := newTestPod("batch", "running", core_v1.PodRunning, now)
+	pending := newTestPod("batch", "pending", core_v1.PodPending, now)
+	succeeded := newTestPod("batch", "succeeded", core_v1.PodSucceeded, now)
+	pods := []interface{}{&running, &pending, &succeeded}
+	forecaster := NewQuotaForecaster(time.Hour, 2*time.Hour)
+	forecaster.Update(pods, quotas, now)
+
+	// a pod replaced every 5 minutes keeps the usage flat but is still forecast
+	for minute := 5; minute <= 20; minute += 5 {
+		forecaster.ObserveCreation(&running, now.Add(time.Duration(minute)*time.Minute))
+	}
+	later := now.Add(20 * time.Minute)
+	forecasts := forecaster.Update(pods, quotas, later)
+	require.Len(t, forecasts, 1)
+	require.Equal(t, "2", forecasts[0].Used.String())
+	require.Equal(t, later.Add(40*time.Minute).Truncate(time.Second), forecasts[0].ExhaustedAt.Time)
+
+	// creations older than the window no longer count
+	forecasts = forecaster.Update(pods, quotas, now.Add(2*time.Hour))
+	require.Nil(t, forecasts[0].ExhaustedAt)
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	core_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/flowcontrol"
+)
+
+const (
+	// eventComponent is the source of the events the monitor emits
+	eventComponent = "k8s-pod-monitor"
+	// eventQueueSize is the number of events waiting to be created, more are dropped
+	eventQueueSize = 100
+	// eventQPS and eventBurst limit the rate events are created at
+	eventQPS   = 2
+	eventBurst = 10
+)
+
+// pendingEvent is an event waiting to be recorded
+type pendingEvent struct {
+	object    core_v1.ObjectReference
+	eventType string
+	reason    string
+	message   string
+}
+
+// EventRecorder emits Events about the objects the monitor observes. Events
+// are queued and created in the background at a limited rate, without the
+// aggregation of the client-go recorder
+type EventRecorder struct {
+	client  kubernetes.Interface
+	limiter flowcontrol.RateLimiter
+	queue   chan pendingEvent
+}
+
+// NewEventRecorder returns a recorder creating events with client once it runs
+func NewEventRecorder(client kubernetes.Interface) *EventRecorder {
+	return &EventRecorder{
+		client:  client,
+		limiter: flowcontrol.NewTokenBucketRateLimiter(eventQPS, eventBurst),
+		queue:   make(chan pendingEvent, eventQueueSize),
+	}
+}
+
+// Eventf queues an event about object. Events are dropped when the queue is
+// full, as events are best effort
+func (r *EventRecorder) Eventf(object core_v1.ObjectReference, eventType, reason, format string, args ...interface{}) {
+	select {
+	case r.queue <- pendingEvent{object: object, eventType: eventType, reason: reason, message: fmt.Sprintf(format, args...)}:
+	default:
+		log.Warnf("Dropping %s event for %s/%s, too many events queued", reason, object.Namespace, object.Name)
+	}
+}
+
+// Run creates the queued events until stopCh is closed
+func (r *EventRecorder) Run(stopCh <-chan struct{}) {
+	for {
+		select {
+		case <-stopCh:
+			return
+		case pending := <-r.queue:
+			r.limiter.Accept()
+			r.create(pending)
+		}
+	}
+}
+
+// create creates an event. Failures are logged, as events are best effort
+func (r *EventRecorder) create(pending pendingEvent) {
+	now := meta_v1.NewTime(time.Now())
+	event := &core_v1.Event{
+		ObjectMeta: meta_v1.ObjectMeta{
+			GenerateName: pending.object.Name + ".",
+			Namespace:    pending.object.Namespace,
+		},
+		InvolvedObject: pending.object,
+		Reason:         pending.reason,
+		Message:        pending.message,
+		Type:           pending.eventType,
+		Source:         core_v1.EventSource{Component: eventComponent},
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Count:          1,
+	}
+	if _, err := r.client.CoreV1().Events(pending.object.Namespace).Create(event); err != nil {
+		log.Errorf("Failed to create %s event for %s/%s: %v", pending.reason, pending.object.Namespace, pending.object.Name, err)
+	}
+}
+
+// podReference returns the reference events about a pod are attached to
+func podReference(pod *core_v1.Pod) core_v1.ObjectReference {
+	return core_v1.ObjectReference{
+		Kind:            "Pod",
+		APIVersion:      "v1",
+		Namespace:       pod.Namespace,
+		Name:            pod.Name,
+		UID:             pod.UID,
+		ResourceVersion: pod.ResourceVersion,
+	}
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"sort"
+
+	"github.com/jayapriya90/k8s-pod-monitor/v1alpha1"
+	core_v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	kindConfigMap             = "ConfigMap"
+	kindSecret                = "Secret"
+	kindPersistentVolumeClaim = "PersistentVolumeClaim"
+	kindServiceAccount        = "ServiceAccount"
+)
+
+// BlockedPod is a pending or waiting pod referencing objects that do not
+// exist, returned by /api/v1/missing-references
+type BlockedPod struct {
+	Namespace string                      `json:"namespace"`
+	Name      string                      `json:"name"`
+	Owner     string                      `json:"owner,omitempty"`
+	Missing   []v1alpha1.MissingReference `json:"missing"`
+}
+
+// podReferences returns the ConfigMaps, Secrets, PersistentVolumeClaims and
+// the ServiceAccount a pod needs to start. References marked optional are skipped
+func podReferences(pod *core_v1.Pod) []v1alpha1.MissingReference {
+	var refs []v1alpha1.MissingReference
+	seen := make(map[v1alpha1.MissingReference]bool)
+	add := func(kind, name string, optional *bool) {
+		ref := v1alpha1.MissingReference{Kind: kind, Name: name}
+		if name == "" || (optional != nil && *optional) || seen[ref] {
+			return
+		}
+		seen[ref] = true
+		refs = append(refs, ref)
+	}
+
+	serviceAccount := pod.Spec.ServiceAccountName
+	if serviceAccount == "" {
+		serviceAccount = "default"
+	}
+	add(kindServiceAccount, serviceAccount, nil)
+	for _, volume := range pod.Spec.Volumes {
+		switch {
+		case volume.ConfigMap != nil:
+			add(kindConfigMap, volume.ConfigMap.Name, volume.ConfigMap.Optional)
+		case volume.Secret != nil:
+			add(kindSecret, volume.Secret.SecretName, volume.Secret.Optional)
+		case volume.PersistentVolumeClaim != nil:
+			add(kindPersistentVolumeClaim, volume.PersistentVolumeClaim.ClaimName, nil)
+		case volume.Projected != nil:
+			for _, source := range volume.Projected.Sources {
+				if source.ConfigMap != nil {
+					add(kindConfigMap, source.ConfigMap.Name, source.ConfigMap.Optional)
+				}
+				if source.Secret != nil {
+					add(kindSecret, source.Secret.Name, source.Secret.Optional)
+				}
+			}
+		}
+	}
+	for _, container := range podContainers(pod) {
+		for _, from := range container.EnvFrom {
+			if from.ConfigMapRef != nil {
+				add(kindConfigMap, from.ConfigMapRef.Name, from.ConfigMapRef.Optional)
+			}
+			if from.SecretRef != nil {
+				add(kindSecret, from.SecretRef.Name, from.SecretRef.Optional)
+			}
+		}
+		for _, env := range container.Env {
+			if env.ValueFrom == nil {
+				continue
+			}
+			if ref := env.ValueFrom.ConfigMapKeyRef; ref != nil {
+				add(kindConfigMap, ref.Name, ref.Optional)
+			}
+			if ref := env.ValueFrom.SecretKeyRef; ref != nil {
+				add(kindSecret, ref.Name, ref.Optional)
+			}
+		}
+	}
+	return refs
+}
+
+// isPodWaiting returns whether a pod is pending or has a container that has not started
+func isPodWaiting(pod *core_v1.Pod) bool {
+	if pod.Status.Phase == core_v1.PodPending {
+		return true
+	}
+	for _, status := range containerStatuses(pod) {
+		if status.State.Waiting != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// ReferenceChecker looks up the objects referenced by waiting pods in the
+// ConfigMap, Secret, PersistentVolumeClaim and ServiceAccount caches
+type ReferenceChecker struct {
+	stores map[string]cache.Store
+}
+
+// NewReferenceChecker returns a checker reading the given caches. A nil cache
+// disables the checks of its kind
+func NewReferenceChecker(configMaps, secrets, claims, serviceAccounts cache.Store) *ReferenceChecker {
+	stores := make(map[string]cache.Store)
+	for kind, store := range map[string]cache.Store{
+		kindConfigMap:             configMaps,
+		kindSecret:                secrets,
+		kindPersistentVolumeClaim: claims,
+		kindServiceAccount:        serviceAccounts,
+	} {
+		if store != nil {
+			stores[kind] = store
+		}
+	}
+	return &ReferenceChecker{stores: stores}
+}
+
+// caches returns the caches the checker reads
+func (r *ReferenceChecker) caches() []cache.Store {
+	stores := make([]cache.Store, 0, len(r.stores))
+	for _, store := range r.stores {
+		stores = append(stores, store)
+	}
+	return stores
+}
+
+// missing returns the references of a pod not found in the caches
+func (r *ReferenceChecker) missing(pod *core_v1.Pod) []v1alpha1.MissingReference {
+	var missing []v1alpha1.MissingReference
+	for _, ref := range podReferences(pod) {
+		store, checked := r.stores[ref.Kind]
+		if !checked {
+			continue
+		}
+		if _, exists, err := store.GetByKey(pod.Namespace + "/" + ref.Name); err == nil && !exists {
+			missing = append(missing, ref)
+		}
+	}
+	return missing
+}
+
+// Check returns the waiting pods with missing references and the missing
+// objects aggregated per namespace
+func (r *ReferenceChecker) Check(pods []*core_v1.Pod) ([]v1alpha1.NamespaceMissingReferences, []BlockedPod) {
+	type namespaceRefs struct {
+		blocked int32
+		pods    map[v1alpha1.MissingReference]int32
+	}
+	namespaces := make(map[string]*namespaceRefs)
+	blocked := []BlockedPod{}
+	for _, pod := range pods {
+		if !isPodWaiting(pod) {
+			continue
+		}
+		missing := r.missing(pod)
+		if len(missing) == 0 {
+			continue
+		}
+		blocked = append(blocked, BlockedPod{Namespace: pod.Namespace, Name: pod.Name, Owner: podOwner(pod), Missing: missing})
+		ns, exists := namespaces[pod.Namespace]
+		if !exists {
+			ns = &namespaceRefs{pods: make(map[v1alpha1.MissingReference]int32)}
+			namespaces[pod.Namespace] = ns
+		}
+		ns.blocked++
+		for _, ref := range missing {
+			ns.pods[ref]++
+		}
+	}
+
+	status := make([]v1alpha1.NamespaceMissingReferences, 0, len(namespaces))
+	for namespace, ns := range namespaces {
+		entry := v1alpha1.NamespaceMissingReferences{Namespace: namespace, BlockedPods: ns.blocked}
+		for ref, count := range ns.pods {
+			ref.Pods = count
+			entry.Missing = append(entry.Missing, ref)
+		}
+		sort.Slice(entry.Missing, func(i, j int) bool {
+			a, b := entry.Missing[i], entry.Missing[j]
+			if a.Kind != b.Kind {
+				return a.Kind < b.Kind
+			}
+			return a.Name < b.Name
+		})
+		status = append(status, entry)
+	}
+	sort.Slice(status, func(i, j int) bool {
+		return status[i].Namespace < status[j].Namespace
+	})
+	sort.Slice(blocked, func(i, j int) bool {
+		a, b := blocked[i], blocked[j]
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return a.Name < b.Name
+	})
+	return status, blocked
+}
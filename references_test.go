@@ -0,0 +1,60 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jayapriya90/k8s-pod-monitor/v1alpha1"
+	"github.com/stretchr/testify/require"
+	core_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestReferenceCheckerMissing(t *testing.T) {
+	configMaps, secrets := cache.NewStore(cache.MetaNamespaceKeyFunc), cache.NewStore(cache.MetaNamespaceKeyFunc)
+	claims, serviceAccounts := cache.NewStore(cache.MetaNamespaceKeyFunc), cache.NewStore(cache.MetaNamespaceKeyFunc)
+	require.NoError(t, configMaps.Add(&core_v1.ConfigMap{ObjectMeta: meta_v1.ObjectMeta{Namespace: "web", Name: "settings"}}))
+	require.NoError(t, serviceAccounts.Add(&core_v1.ServiceAccount{ObjectMeta: meta_v1.ObjectMeta{Namespace: "web", Name: "default"}}))
+	optional := true
+
+	blocked := newTestPod("web", "a", core_v1.PodPending, time.Now())
+	blocked.Spec.Volumes = []core_v1.Volume{
+		{Name: "settings", VolumeSource: core_v1.VolumeSource{ConfigMap: &core_v1.ConfigMapVolumeSource{LocalObjectReference: core_v1.LocalObjectReference{Name: "settings"}}}},
+		{Name: "data", VolumeSource: core_v1.VolumeSource{PersistentVolumeClaim: &core_v1.PersistentVolumeClaimVolumeSource{ClaimName: "data"}}},
+		{Name: "extra", VolumeSource: core_v1.VolumeSource{Secret: &core_v1.SecretVolumeSource{SecretName: "extra", Optional: &optional}}},
+	}
+	blocked.Spec.Containers = []core_v1.Container{{Env: []core_v1.EnvVar{{
+		Name:      "PASSWORD",
+		ValueFrom: &core_v1.EnvVarSource{SecretKeyRef: &core_v1.SecretKeySelector{LocalObjectReference: core_v1.LocalObjectReference{Name: "db"}, Key: "password"}},
+	}}}}
+	running := newTestPod("web", "b", core_v1.PodRunning, time.Now())
+	running.Spec.ServiceAccountName = "gone"
+
+	status, pods := NewReferenceChecker(configMaps, secrets, claims, serviceAccounts).Check([]*core_v1.Pod{&blocked, &running})
+	require.Len(t, pods, 1)
+	require.Equal(t, "a", pods[0].Name)
+	require.Equal(t, []v1alpha1.MissingReference{{Kind: "PersistentVolumeClaim", Name: "data"}, {Kind: "Secret", Name: "db"}}, pods[0].Missing)
+	require.Equal(t, []v1alpha1.NamespaceMissingReferences{{
+		Namespace:   "web",
+		BlockedPods: 1,
+		Missing:     []v1alpha1.MissingReference{{Kind: "PersistentVolumeClaim", Name: "data", Pods: 1}, {Kind: "Secret", Name: "db", Pods: 1}},
+	}}, status)
+}
+
+func TestStripWatchDropsSecretData(t *testing.T) {
+	source := watch.NewFake()
+	stripped, err := stripWatch(source, nil, stripSecret)
+	require.NoError(t, err)
+	defer stripped.Stop()
+
+	go source.Add(&core_v1.Secret{
+		ObjectMeta: meta_v1.ObjectMeta{Namespace: "web", Name: "tls"},
+		Data:       map[string][]byte{"tls.key": []byte("key")},
+	})
+	event := <-stripped.ResultChan()
+	secret := event.Object.(*core_v1.Secret)
+	require.Equal(t, "tls", secret.Name)
+	require.Nil(t, secret.Data)
+}
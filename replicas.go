@@ -0,0 +1,118 @@
+package main
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jayapriya90/k8s-pod-monitor/v1alpha1"
+	apps_v1 "k8s.io/api/apps/v1"
+	core_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// desiredReplicas returns the owner key (`namespace/Kind/name`) and desired
+// pods of a workload. ReplicaSets managed by a Deployment are skipped, their
+// pods count towards the Deployment
+func desiredReplicas(obj interface{}) (string, int32, bool) {
+	replicas := func(replicas *int32) int32 {
+		if replicas == nil {
+			return 1
+		}
+		return *replicas
+	}
+	switch workload := obj.(type) {
+	case *apps_v1.Deployment:
+		return workload.Namespace + "/Deployment/" + workload.Name, replicas(workload.Spec.Replicas), true
+	case *apps_v1.StatefulSet:
+		return workload.Namespace + "/StatefulSet/" + workload.Name, replicas(workload.Spec.Replicas), true
+	case *apps_v1.DaemonSet:
+		return workload.Namespace + "/DaemonSet/" + workload.Name, workload.Status.DesiredNumberScheduled, true
+	case *apps_v1.ReplicaSet:
+		if meta_v1.GetControllerOf(workload) != nil {
+			return "", 0, false
+		}
+		return workload.Namespace + "/ReplicaSet/" + workload.Name, replicas(workload.Spec.Replicas), true
+	}
+	return "", 0, false
+}
+
+// ReplicaTracker compares the running and ready pods of workloads with their
+// desired replicas and remembers since when a workload has been short
+type ReplicaTracker struct {
+	mu        sync.Mutex
+	workloads []cache.Store
+	// since holds when each short owner was first seen short
+	since map[string]time.Time
+}
+
+// NewReplicaTracker returns a tracker reading the desired replicas from the
+// Deployment, StatefulSet, ReplicaSet and DaemonSet caches
+func NewReplicaTracker(workloads ...cache.Store) *ReplicaTracker {
+	return &ReplicaTracker{workloads: workloads, since: make(map[string]time.Time)}
+}
+
+// Update returns the owners with fewer ready pods than desired, most short first
+func (r *ReplicaTracker) Update(pods []*core_v1.Pod, now time.Time) []v1alpha1.ReplicaShortfall {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	running, ready := make(map[string]int32), make(map[string]int32)
+	for _, pod := range pods {
+		if pod.Status.Phase != core_v1.PodRunning {
+			continue
+		}
+		// the pods of a StatefulSet have the same direct owner and workload
+		owners := make(map[string]bool)
+		if owner := podOwner(pod); owner != "" {
+			owners[pod.Namespace+"/"+owner] = true
+		}
+		if workload, _, ok := podRevision(pod); ok {
+			owners[workload] = true
+		}
+		for owner := range owners {
+			running[owner]++
+			if isPodReady(pod) {
+				ready[owner]++
+			}
+		}
+	}
+
+	var shortfalls []v1alpha1.ReplicaShortfall
+	short := make(map[string]bool)
+	for _, store := range r.workloads {
+		for _, obj := range store.List() {
+			owner, desired, ok := desiredReplicas(obj)
+			if !ok || ready[owner] >= desired {
+				continue
+			}
+			short[owner] = true
+			since, exists := r.since[owner]
+			if !exists {
+				since = now
+				r.since[owner] = since
+			}
+			shortfalls = append(shortfalls, v1alpha1.ReplicaShortfall{
+				Owner:   owner,
+				Desired: desired,
+				Running: running[owner],
+				Ready:   ready[owner],
+				Since:   meta_v1.NewTime(since),
+			})
+		}
+	}
+	for owner := range r.since {
+		if !short[owner] {
+			delete(r.since, owner)
+		}
+	}
+	sort.Slice(shortfalls, func(i, j int) bool {
+		a, b := shortfalls[i], shortfalls[j]
+		if a.Desired-a.Ready != b.Desired-b.Ready {
+			return a.Desired-a.Ready > b.Desired-b.Ready
+		}
+		return a.Owner < b.Owner
+	})
+	return shortfalls
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	apps_v1 "k8s.io/api/apps/v1"
+	core_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestReplicaTrackerShortfall(t *testing.T) {
+	now := time.Date(2019, 10, 1, 12, 0, 0, 0, time.UTC)
+	replicas := int32(3)
+	deployments := cache.NewStore(cache.MetaNamespaceKeyFunc)
+	deployments.Add(&apps_v1.Deployment{
+		ObjectMeta: meta_v1.ObjectMeta{Namespace: "web", Name: "frontend"},
+		Spec:       apps_v1.DeploymentSpec{Replicas: &replicas},
+	})
+
+	controller := true
+	pod := newTestPod("web", "frontend-v1-a", core_v1.PodRunning, now)
+	pod.Labels = map[string]string{"pod-template-hash": "v1"}
+	pod.OwnerReferences = []meta_v1.OwnerReference{{Kind: "ReplicaSet", Name: "frontend-v1", Controller: &controller}}
+	pod.Status.Conditions = []core_v1.PodCondition{{Type: core_v1.PodReady, Status: core_v1.ConditionTrue}}
+
+	tracker := NewReplicaTracker(deployments)
+	tracker.Update([]*core_v1.Pod{&pod}, now)
+	shortfalls := tracker.Update([]*core_v1.Pod{&pod}, now.Add(time.Minute))
+	require.Len(t, shortfalls, 1)
+	require.Equal(t, "web/Deployment/frontend", shortfalls[0].Owner)
+	require.Equal(t, int32(1), shortfalls[0].Ready)
+	require.Equal(t, now, shortfalls[0].Since.Time)
+
+	replicas = 1
+	require.Empty(t, tracker.Update([]*core_v1.Pod{&pod}, now.Add(2*time.Minute)))
+}
+
+func TestReplicaTrackerCountsStatefulSetPodsOnce(t *testing.T) {
+	now := time.Date(2019, 10, 1, 12, 0, 0, 0, time.UTC)
+	replicas := int32(3)
+	statefulSets := cache.NewStore(cache.MetaNamespaceKeyFunc)
+	statefulSets.Add(&apps_v1.StatefulSet{
+		ObjectMeta: meta_v1.ObjectMeta{Namespace: "db", Name: "postgres"},
+		Spec:       apps_v1.StatefulSetSpec{Replicas: &replicas},
+	})
+
+	controller := true
+	var pods []*core_v1.Pod
+	for _, name := range []string{"postgres-0", "postgres-1"} {
+		pod := newTestPod("db", name, core_v1.PodRunning, now)
+		pod.Labels = map[string]string{apps_v1.StatefulSetRevisionLabel: "postgres-v1"}
+		pod.OwnerReferences = []meta_v1.OwnerReference{{Kind: "StatefulSet", Name: "postgres", Controller: &controller}}
+		pod.Status.Conditions = []core_v1.PodCondition{{Type: core_v1.PodReady, Status: core_v1.ConditionTrue}}
+		pods = append(pods, &pod)
+	}
+
+	shortfalls := NewReplicaTracker(statefulSets).Update(pods, now)
+	require.Len(t, shortfalls, 1)
+	require.Equal(t, "db/StatefulSet/postgres", shortfalls[0].Owner)
+	require.Equal(t, int32(2), shortfalls[0].Running)
+	require.Equal(t, int32(2), shortfalls[0].Ready)
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// UsageReportLine is the usage of a namespace or team over the report range
+type UsageReportLine struct {
+	Namespace         string  `json:"namespace,omitempty"`
+	Team              string  `json:"team,omitempty"`
+	PodSeconds        float64 `json:"podSeconds"`
+	CPUCoreSeconds    float64 `json:"cpuCoreSeconds"`
+	MemoryByteSeconds float64 `json:"memoryByteSeconds"`
+}
+
+// UsageReport is the output of the report subcommand
+type UsageReport struct {
+	From  time.Time         `json:"from"`
+	To    time.Time         `json:"to"`
+	By    string            `json:"by"`
+	Lines []UsageReportLine `json:"lines"`
+}
+
+// runReport implements the report subcommand. It reads the usage persisted by
+// the controller and sums it per namespace or team over a time range
+func runReport(args []string) error {
+	flags := flag.NewFlagSet("report", flag.ExitOnError)
+	from := flags.String("from", "", "start of the range, RFC3339. Defaults to 24 hours before -to")
+	to := flags.String("to", "", "end of the range, RFC3339. Defaults to now")
+	by := flags.String("by", "namespace", "group usage by namespace, team or namespace,team")
+	output := flags.String("o", "csv", "output format: csv or json")
+	configMap := flags.String("usage-configmap", "pod-monitor-usage", "ConfigMap in the default namespace the controller persists usage in")
+	flags.Parse(args)
+
+	if *by != "namespace" && *by != "team" && *by != "namespace,team" {
+		return fmt.Errorf("unknown grouping %q", *by)
+	}
+	if *output != "csv" && *output != "json" {
+		return fmt.Errorf("unknown output format %q", *output)
+	}
+	end := time.Now()
+	if *to != "" {
+		var err error
+		if end, err = time.Parse(time.RFC3339, *to); err != nil {
+			return fmt.Errorf("invalid -to: %v", err)
+		}
+	}
+	start := end.Add(-24 * time.Hour)
+	if *from != "" {
+		var err error
+		if start, err = time.Parse(time.RFC3339, *from); err != nil {
+			return fmt.Errorf("invalid -from: %v", err)
+		}
+	}
+
+	client, _ := GetKubernetesClient()
+	snapshot, err := NewUsageStore(client, meta_v1.NamespaceDefault, *configMap).Load()
+	if err != nil {
+		return err
+	}
+
+	report := buildUsageReport(snapshot.Records, start, end, *by)
+	if *output == "json" {
+		return printJSON(os.Stdout, report)
+	}
+	return writeUsageCSV(os.Stdout, report)
+}
+
+// buildUsageReport sums the hourly records in [from, to). Usage is kept per
+// hour, so a record counts when its hour overlaps the range
+func buildUsageReport(records []UsageRecord, from, to time.Time, by string) UsageReport {
+	report := UsageReport{From: from, To: to, By: by, Lines: []UsageReportLine{}}
+	lines := make(map[string]*UsageReportLine)
+	for _, record := range records {
+		if record.Hour.Before(from.Truncate(time.Hour)) || !record.Hour.Before(to) {
+			continue
+		}
+		line := UsageReportLine{}
+		switch by {
+		case "namespace":
+			line.Namespace = record.Namespace
+		case "team":
+			line.Team = record.Team
+		default:
+			line.Namespace, line.Team = record.Namespace, record.Team
+		}
+		key := line.Namespace + "/" + line.Team
+		sum, exists := lines[key]
+		if !exists {
+			sum = &line
+			lines[key] = sum
+		}
+		sum.PodSeconds += record.PodSeconds
+		sum.CPUCoreSeconds += record.CPUCoreSeconds
+		sum.MemoryByteSeconds += record.MemoryByteSeconds
+	}
+	for _, line := range lines {
+		report.Lines = append(report.Lines, *line)
+	}
+	sort.Slice(report.Lines, func(i, j int) bool {
+		a, b := report.Lines[i], report.Lines[j]
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return a.Team < b.Team
+	})
+	return report
+}
+
+func writeUsageCSV(w io.Writer, report UsageReport) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"from", "to", "namespace", "team", "pod_seconds", "cpu_core_seconds", "memory_byte_seconds"})
+	format := func(value float64) string {
+		return strconv.FormatFloat(value, 'f', 0, 64)
+	}
+	for _, line := range report.Lines {
+		writer.Write([]string{
+			report.From.Format(time.RFC3339),
+			report.To.Format(time.RFC3339),
+			line.Namespace,
+			line.Team,
+			format(line.PodSeconds),
+			format(line.CPUCoreSeconds),
+			format(line.MemoryByteSeconds),
+		})
+	}
+	writer.Flush()
+	return writer.Error()
+}
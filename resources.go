@@ -0,0 +1,158 @@
+package main
+
+import (
+	"sort"
+
+	"github.com/jayapriya90/k8s-pod-monitor/v1alpha1"
+	core_v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// accountedResources are the resources summed up for running pods
+var accountedResources = []core_v1.ResourceName{
+	core_v1.ResourceCPU,
+	core_v1.ResourceMemory,
+	core_v1.ResourceEphemeralStorage,
+}
+
+// podResources returns the effective requests and limits of a pod the way the
+// scheduler sees them: the larger of the sum of the app containers and the
+// largest init container, plus the pod overhead
+func podResources(pod *core_v1.Pod) (core_v1.ResourceList, core_v1.ResourceList) {
+	requests, limits := core_v1.ResourceList{}, core_v1.ResourceList{}
+	for _, container := range pod.Spec.Containers {
+		addResources(requests, container.Resources.Requests)
+		addResources(limits, container.Resources.Limits)
+	}
+	for _, container := range pod.Spec.InitContainers {
+		maxResources(requests, container.Resources.Requests)
+		maxResources(limits, container.Resources.Limits)
+	}
+	addResources(requests, pod.Spec.Overhead)
+	// overhead only counts towards limits that are set
+	for name, quantity := range pod.Spec.Overhead {
+		if limit, exists := limits[name]; exists {
+			limit.Add(quantity)
+			limits[name] = limit
+		}
+	}
+	return requests, limits
+}
+
+// addResources adds the accounted resources of src to dst
+func addResources(dst, src core_v1.ResourceList) {
+	for _, name := range accountedResources {
+		quantity, exists := src[name]
+		if !exists {
+			continue
+		}
+		sum := dst[name]
+		sum.Add(quantity)
+		dst[name] = sum
+	}
+}
+
+// maxResources raises the accounted resources of dst to the ones of src where src is larger
+func maxResources(dst, src core_v1.ResourceList) {
+	for _, name := range accountedResources {
+		quantity, exists := src[name]
+		if !exists {
+			continue
+		}
+		if current, exists := dst[name]; !exists || quantity.Cmp(current) > 0 {
+			dst[name] = quantity.DeepCopy()
+		}
+	}
+}
+
+// podQOSClass returns the QoS class the API server assigned to the pod,
+// falling back to deriving it from the container resources
+func podQOSClass(pod *core_v1.Pod) core_v1.PodQOSClass {
+	if pod.Status.QOSClass != "" {
+		return pod.Status.QOSClass
+	}
+	guaranteed, bestEffort := true, true
+	for _, container := range podContainers(pod) {
+		if len(container.Resources.Requests) > 0 || len(container.Resources.Limits) > 0 {
+			bestEffort = false
+		}
+		for _, name := range []core_v1.ResourceName{core_v1.ResourceCPU, core_v1.ResourceMemory} {
+			limit, hasLimit := container.Resources.Limits[name]
+			request, hasRequest := container.Resources.Requests[name]
+			if !hasLimit || (hasRequest && request.Cmp(limit) != 0) {
+				guaranteed = false
+			}
+		}
+	}
+	switch {
+	case bestEffort:
+		return core_v1.PodQOSBestEffort
+	case guaranteed:
+		return core_v1.PodQOSGuaranteed
+	default:
+		return core_v1.PodQOSBurstable
+	}
+}
+
+// resourceAccumulator sums resource usage per name
+type resourceAccumulator map[string]*v1alpha1.ResourceUsage
+
+func (a resourceAccumulator) add(name string, requests, limits core_v1.ResourceList) {
+	usage, exists := a[name]
+	if !exists {
+		usage = &v1alpha1.ResourceUsage{Requests: core_v1.ResourceList{}, Limits: core_v1.ResourceList{}}
+		a[name] = usage
+	}
+	usage.Pods++
+	addResources(usage.Requests, requests)
+	addResources(usage.Limits, limits)
+}
+
+// breakdown returns the accumulated usage sorted by name
+func (a resourceAccumulator) breakdown() []v1alpha1.ResourceBreakdown {
+	breakdown := make([]v1alpha1.ResourceBreakdown, 0, len(a))
+	for name, usage := range a {
+		breakdown = append(breakdown, v1alpha1.ResourceBreakdown{Name: name, ResourceUsage: *usage})
+	}
+	sort.Slice(breakdown, func(i, j int) bool {
+		return breakdown[i].Name < breakdown[j].Name
+	})
+	return breakdown
+}
+
+// resourceStatus sums the requests and limits of the running pods, in total
+// and per namespace, owner and QoS class
+func resourceStatus(pods map[string]*core_v1.Pod) *v1alpha1.ResourceStatus {
+	total := resourceAccumulator{}
+	namespaces, owners, qosClasses := resourceAccumulator{}, resourceAccumulator{}, resourceAccumulator{}
+	for _, pod := range pods {
+		if pod.Status.Phase != core_v1.PodRunning {
+			continue
+		}
+		requests, limits := podResources(pod)
+		total.add("", requests, limits)
+		namespaces.add(pod.Namespace, requests, limits)
+		owner := podOwner(pod)
+		if owner == "" {
+			owner = noOwner
+		}
+		owners.add(pod.Namespace+"/"+owner, requests, limits)
+		qosClasses.add(string(podQOSClass(pod)), requests, limits)
+	}
+
+	status := &v1alpha1.ResourceStatus{
+		Total:      v1alpha1.ResourceUsage{Requests: core_v1.ResourceList{}, Limits: core_v1.ResourceList{}},
+		Namespaces: namespaces.breakdown(),
+		Owners:     owners.breakdown(),
+		QOSClasses: qosClasses.breakdown(),
+	}
+	if usage, exists := total[""]; exists {
+		status.Total = *usage
+	}
+	return status
+}
+
+// quantityValue converts a quantity to a float, in cores for CPU and bytes otherwise
+func quantityValue(quantity resource.Quantity) float64 {
+	return float64(quantity.MilliValue()) / 1000
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	core_v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestRunningPodResources(t *testing.T) {
+	now := time.Now()
+	container := func(cpu, memory string) core_v1.Container {
+		resources := core_v1.ResourceList{
+			core_v1.ResourceCPU:    resource.MustParse(cpu),
+			core_v1.ResourceMemory: resource.MustParse(memory),
+		}
+		return core_v1.Container{Resources: core_v1.ResourceRequirements{Requests: resources, Limits: resources}}
+	}
+
+	web := newTestPod("web", "a", core_v1.PodRunning, now)
+	web.Spec.Containers = []core_v1.Container{container("100m", "64Mi"), container("200m", "64Mi")}
+	// the init container needs more memory than the app containers together
+	web.Spec.InitContainers = []core_v1.Container{container("50m", "256Mi")}
+	idle := newTestPod("web", "b", core_v1.PodRunning, now)
+	done := newTestPod("web", "c", core_v1.PodSucceeded, now)
+	done.Spec.Containers = []core_v1.Container{container("1", "1Gi")}
+
+	tracker := NewPodTracker(time.Time{})
+	for _, pod := range []core_v1.Pod{web, idle, done} {
+		pod := pod
+		tracker.Observe(pod.Namespace+"/"+pod.Name, &pod)
+	}
+
+	resources := tracker.Status().Resources
+	require.Equal(t, int32(2), resources.Total.Pods)
+	cpu := resources.Total.Requests[core_v1.ResourceCPU]
+	memory := resources.Total.Limits[core_v1.ResourceMemory]
+	require.Equal(t, "300m", cpu.String())
+	require.Equal(t, "256Mi", memory.String())
+
+	require.Len(t, resources.QOSClasses, 2)
+	require.Equal(t, string(core_v1.PodQOSBestEffort), resources.QOSClasses[0].Name)
+	require.Equal(t, string(core_v1.PodQOSGuaranteed), resources.QOSClasses[1].Name)
+
+	tracker.Forget("web/a")
+	require.Empty(t, tracker.Status().Resources.Total.Requests)
+}
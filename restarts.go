@@ -0,0 +1,167 @@
+package main
+
+import (
+	"sort"
+
+	"github.com/jayapriya90/k8s-pod-monitor/v1alpha1"
+	core_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// reasonOOMKilled is the termination reason of containers killed for exceeding their memory limit
+const reasonOOMKilled = "OOMKilled"
+
+// topRestartOwners is the number of owners listed in the restart status
+const topRestartOwners = 10
+
+// restartAccumulator sums container restarts and terminations per name
+type restartAccumulator map[string]*v1alpha1.RestartBreakdown
+
+// add records restarts of a container that terminated as described by terminated
+func (a restartAccumulator) add(name string, restarts int32, terminated *core_v1.ContainerStateTerminated) {
+	breakdown, exists := a[name]
+	if !exists {
+		breakdown = &v1alpha1.RestartBreakdown{Name: name, Terminations: make(map[string]int32)}
+		a[name] = breakdown
+	}
+	breakdown.Restarts += restarts
+	if terminated == nil {
+		return
+	}
+	count := restarts
+	if count == 0 {
+		count = 1
+	}
+	breakdown.Terminations[terminated.Reason] += count
+	if !terminated.FinishedAt.Before(&breakdown.LastTerminationTime) {
+		breakdown.LastReason = terminated.Reason
+		breakdown.LastExitCode = terminated.ExitCode
+		breakdown.LastTerminationTime = terminated.FinishedAt
+	}
+}
+
+// sorted returns copies of the breakdowns ordered by less
+func (a restartAccumulator) sorted(less func(a, b *v1alpha1.RestartBreakdown) bool) []v1alpha1.RestartBreakdown {
+	breakdowns := make([]*v1alpha1.RestartBreakdown, 0, len(a))
+	for _, breakdown := range a {
+		breakdowns = append(breakdowns, breakdown)
+	}
+	sort.Slice(breakdowns, func(i, j int) bool {
+		return less(breakdowns[i], breakdowns[j])
+	})
+	sorted := make([]v1alpha1.RestartBreakdown, 0, len(breakdowns))
+	for _, breakdown := range breakdowns {
+		sorted = append(sorted, *breakdown.DeepCopy())
+	}
+	return sorted
+}
+
+// restartOwner returns the key the restarts of a pod are summed under per owner
+func restartOwner(pod *core_v1.Pod) string {
+	owner := podOwner(pod)
+	if owner == "" {
+		owner = noOwner
+	}
+	return pod.Namespace + "/" + owner
+}
+
+// forgetRestarts drops the restarts of the owner of a forgotten pod once no
+// tracked pod is left with that owner, as owners like the ReplicaSets of
+// past rollouts do not come back. t.mu must be held
+func (t *PodTracker) forgetRestarts(pod *core_v1.Pod) {
+	owner := restartOwner(pod)
+	if _, exists := t.ownerRestarts[owner]; !exists {
+		return
+	}
+	for _, tracked := range t.pods {
+		if restartOwner(tracked) == owner {
+			return
+		}
+	}
+	delete(t.ownerRestarts, owner)
+}
+
+// recordRestarts adds the container restarts and terminations since the
+// previous observation of a pod. The first observation of a pod is the
+// baseline, restarts and terminations from before it are not counted
+func (t *PodTracker) recordRestarts(pod, previous *core_v1.Pod) {
+	if previous == nil {
+		return
+	}
+	seen := make(map[string]core_v1.ContainerStatus)
+	for _, status := range containerStatuses(previous) {
+		seen[status.Name] = status
+	}
+	owner := restartOwner(pod)
+	record := func(restarts int32, terminated *core_v1.ContainerStateTerminated) {
+		t.namespaceRestarts.add(pod.Namespace, restarts, terminated)
+		t.ownerRestarts.add(owner, restarts, terminated)
+	}
+
+	for _, status := range containerStatuses(pod) {
+		old, exists := seen[status.Name]
+		if restarts := status.RestartCount - old.RestartCount; restarts > 0 {
+			record(restarts, status.LastTerminationState.Terminated)
+		}
+		// containers that terminated for good, without a restart
+		if status.State.Terminated != nil && (!exists || old.State.Terminated == nil) {
+			record(0, status.State.Terminated)
+		}
+	}
+}
+
+// restartStatus builds the restart status from the accumulated restarts
+func (t *PodTracker) restartStatus() *v1alpha1.RestartStatus {
+	status := &v1alpha1.RestartStatus{}
+	for _, breakdown := range t.namespaceRestarts {
+		status.Restarts += breakdown.Restarts
+		status.OOMKilled += breakdown.Terminations[reasonOOMKilled]
+	}
+	status.Namespaces = t.namespaceRestarts.sorted(func(a, b *v1alpha1.RestartBreakdown) bool {
+		return a.Name < b.Name
+	})
+	owners := t.ownerRestarts.sorted(func(a, b *v1alpha1.RestartBreakdown) bool {
+		if a.Restarts != b.Restarts {
+			return a.Restarts > b.Restarts
+		}
+		return a.Name < b.Name
+	})
+	for _, owner := range owners {
+		if owner.Restarts == 0 || len(status.TopOwners) == topRestartOwners {
+			break
+		}
+		status.TopOwners = append(status.TopOwners, owner)
+	}
+	return status
+}
+
+// ContainerRecord is the restart state of a container returned with a pod
+type ContainerRecord struct {
+	Name         string `json:"name"`
+	Ready        bool   `json:"ready"`
+	RestartCount int32  `json:"restartCount"`
+	LastReason   string `json:"lastReason,omitempty"`
+	LastExitCode *int32 `json:"lastExitCode,omitempty"`
+	// LastTerminated is when the container last terminated
+	LastTerminated *meta_v1.Time `json:"lastTerminated,omitempty"`
+}
+
+// containerRecords returns the restart state of the containers of a pod. The
+// termination shown is the current one for terminated containers and the
+// previous one otherwise
+func containerRecords(pod *core_v1.Pod) []ContainerRecord {
+	var records []ContainerRecord
+	for _, status := range containerStatuses(pod) {
+		record := ContainerRecord{Name: status.Name, Ready: status.Ready, RestartCount: status.RestartCount}
+		terminated := status.State.Terminated
+		if terminated == nil {
+			terminated = status.LastTerminationState.Terminated
+		}
+		if terminated != nil {
+			exitCode, finished := terminated.ExitCode, terminated.FinishedAt
+			record.LastReason, record.LastExitCode, record.LastTerminated = terminated.Reason, &exitCode, &finished
+		}
+		records = append(records, record)
+	}
+	return records
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	core_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestPodTrackerCountsContainerRestarts(t *testing.T) {
+	started := time.Now()
+	tracker := NewPodTracker(started)
+	terminated := func(reason string, exitCode int32) *core_v1.ContainerStateTerminated {
+		return &core_v1.ContainerStateTerminated{Reason: reason, ExitCode: exitCode, FinishedAt: meta_v1.Now()}
+	}
+
+	pod := newTestPod("web", "a", core_v1.PodRunning, started.Add(time.Second))
+	pod.Status.ContainerStatuses = []core_v1.ContainerStatus{{Name: "app"}}
+	tracker.Observe("web/a", &pod)
+
+	restarted := pod.DeepCopy()
+	restarted.Status.ContainerStatuses[0].RestartCount = 2
+	restarted.Status.ContainerStatuses[0].LastTerminationState.Terminated = terminated("OOMKilled", 137)
+	tracker.Observe("web/a", restarted)
+	// observing the same state again adds nothing
+	tracker.Observe("web/a", restarted.DeepCopy())
+
+	// restarts of pods that were running before the monitor started are not counted
+	old := newTestPod("web", "old", core_v1.PodRunning, started.Add(-time.Hour))
+	old.Status.ContainerStatuses = []core_v1.ContainerStatus{{Name: "app", RestartCount: 5}}
+	tracker.Observe("web/old", &old)
+
+	restarts := tracker.Status().Restarts
+	require.Equal(t, int32(2), restarts.Restarts)
+	require.Equal(t, int32(2), restarts.OOMKilled)
+	require.Len(t, restarts.Namespaces, 1)
+	require.Equal(t, "OOMKilled", restarts.Namespaces[0].LastReason)
+	require.Equal(t, int32(137), restarts.Namespaces[0].LastExitCode)
+	require.Len(t, restarts.TopOwners, 1)
+	require.Equal(t, "web/"+noOwner, restarts.TopOwners[0].Name)
+}
+
+func TestPodTrackerForgetsRestartsOfGoneOwners(t *testing.T) {
+	tracker := NewPodTracker(time.Time{})
+	controller := true
+	for _, name := range []string{"a", "b"} {
+		pod := newTestPod("web", "frontend-v1-"+name, core_v1.PodRunning, time.Now())
+		pod.OwnerReferences = []meta_v1.OwnerReference{{Kind: "ReplicaSet", Name: "frontend-v1", Controller: &controller}}
+		pod.Status.ContainerStatuses = []core_v1.ContainerStatus{{Name: "app"}}
+		tracker.Observe("web/"+pod.Name, &pod)
+		restarted := pod.DeepCopy()
+		restarted.Status.ContainerStatuses[0].RestartCount = 1
+		tracker.Observe("web/"+pod.Name, restarted)
+	}
+
+	// the owner is kept while it has pods
+	tracker.Forget("web/frontend-v1-a")
+	require.Len(t, tracker.Status().Restarts.TopOwners, 1)
+
+	tracker.Forget("web/frontend-v1-b")
+	restarts := tracker.Status().Restarts
+	require.Empty(t, restarts.TopOwners)
+	require.Equal(t, int32(2), restarts.Restarts)
+}
+
+func TestPodTrackerTakesFirstObservationAsRestartBaseline(t *testing.T) {
+	// the monitor restarted, the pod-monitor resource already existed
+	tracker := NewPodTracker(time.Time{})
+	pod := newTestPod("web", "a", core_v1.PodRunning, time.Now().Add(-time.Hour))
+	pod.Status.InitContainerStatuses = []core_v1.ContainerStatus{{Name: "init", State: core_v1.ContainerState{
+		Terminated: &core_v1.ContainerStateTerminated{Reason: "Completed"},
+	}}}
+	pod.Status.ContainerStatuses = []core_v1.ContainerStatus{{Name: "app", RestartCount: 3, LastTerminationState: core_v1.ContainerState{
+		Terminated: &core_v1.ContainerStateTerminated{Reason: "OOMKilled", ExitCode: 137},
+	}}}
+	tracker.Observe("web/a", &pod)
+	restarts := tracker.Status().Restarts
+	require.Zero(t, restarts.Restarts)
+	require.Zero(t, restarts.OOMKilled)
+	require.Empty(t, restarts.TopOwners)
+
+	// later restarts are counted
+	restarted := pod.DeepCopy()
+	restarted.Status.ContainerStatuses[0].RestartCount = 4
+	tracker.Observe("web/a", restarted)
+	restarts = tracker.Status().Restarts
+	require.Equal(t, int32(1), restarts.Restarts)
+	require.Equal(t, int32(1), restarts.OOMKilled)
+}
@@ -0,0 +1,309 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jayapriya90/k8s-pod-monitor/v1alpha1"
+	apps_v1 "k8s.io/api/apps/v1"
+	core_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// completedRolloutRetention is how long a completed rollout stays in the status
+const completedRolloutRetention = time.Hour
+
+// deploymentRevisionAnnotation holds the revision of a Deployment on its ReplicaSets
+const deploymentRevisionAnnotation = "deployment.kubernetes.io/revision"
+
+// podRevision returns the workload (`namespace/Kind/name`) of a Deployment or
+// StatefulSet pod and the revision of the workload it runs
+func podRevision(pod *core_v1.Pod) (string, string, bool) {
+	for _, ref := range pod.OwnerReferences {
+		if ref.Controller == nil || !*ref.Controller {
+			continue
+		}
+		switch ref.Kind {
+		case "ReplicaSet":
+			// the ReplicaSets of a Deployment are named after it with the pod template hash appended
+			hash := pod.Labels[apps_v1.DefaultDeploymentUniqueLabelKey]
+			if hash == "" || !strings.HasSuffix(ref.Name, "-"+hash) {
+				return "", "", false
+			}
+			return pod.Namespace + "/Deployment/" + strings.TrimSuffix(ref.Name, "-"+hash), ref.Name, true
+		case "StatefulSet":
+			revision := pod.Labels[apps_v1.StatefulSetRevisionLabel]
+			if revision == "" {
+				return "", "", false
+			}
+			return pod.Namespace + "/StatefulSet/" + ref.Name, revision, true
+		}
+	}
+	return "", "", false
+}
+
+// isPodCrashing reports whether a pod failed or one of its containers is in CrashLoopBackOff
+func isPodCrashing(pod *core_v1.Pod) bool {
+	if pod.Status.Phase == core_v1.PodFailed {
+		return true
+	}
+	for _, status := range containerStatuses(pod) {
+		if status.State.Waiting != nil && status.State.Waiting.Reason == "CrashLoopBackOff" {
+			return true
+		}
+	}
+	return false
+}
+
+// isPodReady reports whether the Ready condition of a pod is true
+func isPodReady(pod *core_v1.Pod) bool {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == core_v1.PodReady {
+			return condition.Status == core_v1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// revisionPods are the current pods of a workload revision
+type revisionPods struct {
+	health  v1alpha1.RevisionHealth
+	created time.Time
+	newest  time.Time
+}
+
+func (r *revisionPods) add(pod *core_v1.Pod) {
+	created := pod.CreationTimestamp.Time
+	if r.health.Pods == 0 || created.Before(r.created) {
+		r.created = created
+	}
+	if created.After(r.newest) {
+		r.newest = created
+	}
+	r.health.Pods++
+	if isPodReady(pod) {
+		r.health.ReadyPods++
+	}
+	if isPodCrashing(pod) {
+		r.health.CrashingPods++
+	}
+	for _, status := range containerStatuses(pod) {
+		r.health.Restarts += status.RestartCount
+	}
+}
+
+// RolloutTracker follows the revisions of Deployments and StatefulSets through
+// their pods. A rollout starts when the workload moves to a new revision and
+// completes once only ready pods of the new revision are left
+type RolloutTracker struct {
+	mu           sync.Mutex
+	stallAfter   time.Duration
+	replicaSets  cache.Store
+	statefulSets cache.Store
+	rollouts     map[string]*v1alpha1.RolloutStatus
+	// updated is the time of the last update
+	updated time.Time
+}
+
+// NewRolloutTracker returns a tracker reporting rollouts as stalled once they
+// take longer than stallAfter. The current revision of a workload is read from
+// the ReplicaSet and StatefulSet caches, and taken from the newest pod for
+// workloads not found in them
+func NewRolloutTracker(stallAfter time.Duration, replicaSets, statefulSets cache.Store) *RolloutTracker {
+	return &RolloutTracker{
+		stallAfter:   stallAfter,
+		replicaSets:  replicaSets,
+		statefulSets: statefulSets,
+		rollouts:     make(map[string]*v1alpha1.RolloutStatus),
+	}
+}
+
+// currentRevisions returns the revision each workload is rolling out: the
+// ReplicaSet of a Deployment with the highest revision annotation and the
+// update revision of a StatefulSet
+func (r *RolloutTracker) currentRevisions() map[string]string {
+	current := make(map[string]string)
+	if r.replicaSets != nil {
+		revisions := make(map[string]int64)
+		for _, obj := range r.replicaSets.List() {
+			replicaSet, ok := obj.(*apps_v1.ReplicaSet)
+			if !ok {
+				continue
+			}
+			ref := meta_v1.GetControllerOf(replicaSet)
+			if ref == nil || ref.Kind != "Deployment" {
+				continue
+			}
+			revision, err := strconv.ParseInt(replicaSet.Annotations[deploymentRevisionAnnotation], 10, 64)
+			if err != nil {
+				continue
+			}
+			workload := replicaSet.Namespace + "/Deployment/" + ref.Name
+			if last, exists := revisions[workload]; !exists || revision > last {
+				revisions[workload] = revision
+				current[workload] = replicaSet.Name
+			}
+		}
+	}
+	if r.statefulSets != nil {
+		for _, obj := range r.statefulSets.List() {
+			statefulSet, ok := obj.(*apps_v1.StatefulSet)
+			if ok && statefulSet.Status.UpdateRevision != "" {
+				current[statefulSet.Namespace+"/StatefulSet/"+statefulSet.Name] = statefulSet.Status.UpdateRevision
+			}
+		}
+	}
+	return current
+}
+
+// Update advances the rollouts with the current pods and returns the rollouts
+// in progress or completed within the last hour
+func (r *RolloutTracker) Update(pods []*core_v1.Pod, now time.Time) []v1alpha1.RolloutStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	workloads := make(map[string]map[string]*revisionPods)
+	for _, pod := range pods {
+		if pod.Status.Phase == core_v1.PodSucceeded || pod.DeletionTimestamp != nil {
+			continue
+		}
+		workload, revision, ok := podRevision(pod)
+		if !ok {
+			continue
+		}
+		if workloads[workload] == nil {
+			workloads[workload] = make(map[string]*revisionPods)
+		}
+		if workloads[workload][revision] == nil {
+			workloads[workload][revision] = &revisionPods{}
+		}
+		workloads[workload][revision].add(pod)
+	}
+	for workload := range r.rollouts {
+		if _, exists := workloads[workload]; !exists {
+			delete(r.rollouts, workload)
+		}
+	}
+
+	currentRevisions := r.currentRevisions()
+	var rollouts []v1alpha1.RolloutStatus
+	for workload, revisions := range workloads {
+		current, known := currentRevisions[workload]
+		if !known {
+			// without the workload the revision with the most recently created pod is the one being rolled out
+			for revision, pods := range revisions {
+				if current == "" || pods.newest.After(revisions[current].newest) {
+					current = revision
+				}
+			}
+		}
+		if revisions[current] == nil {
+			// the new revision has no pods yet
+			revisions[current] = &revisionPods{created: now}
+		}
+		rollout, exists := r.rollouts[workload]
+		if !exists {
+			rollout = &v1alpha1.RolloutStatus{Workload: workload, Revision: current}
+			r.rollouts[workload] = rollout
+			// revisions running side by side when the monitor starts are a rollout in progress
+			if len(revisions) > 1 {
+				rollout.Started = meta_v1.NewTime(revisions[current].created)
+			}
+		}
+		if rollout.Revision != current {
+			// the revision changed since the last update. Pods of the revision
+			// created before, as on a rollback, do not date the rollout back
+			started := revisions[current].created
+			if started.Before(r.updated) {
+				started = r.updated
+			}
+			*rollout = v1alpha1.RolloutStatus{
+				Workload:         workload,
+				Revision:         current,
+				PreviousRevision: rollout.Revision,
+				Started:          meta_v1.NewTime(started),
+				// the previous revision is known from the last update in case its pods are already gone
+				Previous: rollout.New,
+			}
+		}
+		if rollout.Started.IsZero() {
+			// no rollout observed yet, the health of the current revision is kept for the next rollout
+			rollout.New = revisions[current].health
+			continue
+		}
+
+		rollout.New = revisions[current].health
+		previous := revisions[rollout.PreviousRevision]
+		if previous != nil {
+			rollout.Previous = previous.health
+		}
+		oldPods := 0
+		for revision, pods := range revisions {
+			if revision != current {
+				oldPods += int(pods.health.Pods)
+			}
+		}
+		if rollout.Completed == nil {
+			if oldPods == 0 && rollout.New.ReadyPods == rollout.New.Pods {
+				completed := meta_v1.NewTime(now)
+				rollout.Completed = &completed
+				rollout.Stalled = false
+				rollout.DurationSeconds = int64(now.Sub(rollout.Started.Time).Seconds())
+			} else {
+				rollout.Stalled = now.Sub(rollout.Started.Time) > r.stallAfter
+			}
+		}
+		rollout.Unhealthy = rollout.New.CrashingPods > 0 && crashRate(rollout.New) > crashRate(rollout.Previous)
+		if rollout.Completed != nil && now.Sub(rollout.Completed.Time) > completedRolloutRetention && !rollout.Unhealthy {
+			continue
+		}
+		rollouts = append(rollouts, *rollout.DeepCopy())
+	}
+	r.updated = now
+	sort.Slice(rollouts, func(i, j int) bool {
+		return rollouts[i].Workload < rollouts[j].Workload
+	})
+	return rollouts
+}
+
+// crashRate is the fraction of the pods of a revision that are crashing
+func crashRate(health v1alpha1.RevisionHealth) float64 {
+	if health.Pods == 0 {
+		return 0
+	}
+	return float64(health.CrashingPods) / float64(health.Pods)
+}
+
+// rolloutsDegradedCondition reports rollouts that are stalled or whose new revision crashes more than the previous one
+func rolloutsDegradedCondition(rollouts []v1alpha1.RolloutStatus, stallAfter time.Duration) v1alpha1.PodMonitorCondition {
+	var stalled, unhealthy []string
+	for _, rollout := range rollouts {
+		if rollout.Stalled {
+			stalled = append(stalled, rollout.Workload)
+		}
+		if rollout.Unhealthy {
+			unhealthy = append(unhealthy, rollout.Workload)
+		}
+	}
+	if len(stalled) == 0 && len(unhealthy) == 0 {
+		return v1alpha1.PodMonitorCondition{Type: conditionRolloutsDegraded, Status: meta_v1.ConditionFalse, Reason: "RolloutsHealthy"}
+	}
+	var messages []string
+	if len(unhealthy) > 0 {
+		messages = append(messages, fmt.Sprintf("new revision crashing: %s", summarizeKeys(unhealthy, 5)))
+	}
+	if len(stalled) > 0 {
+		messages = append(messages, fmt.Sprintf("in progress for more than %s: %s", stallAfter, summarizeKeys(stalled, 5)))
+	}
+	return v1alpha1.PodMonitorCondition{
+		Type:    conditionRolloutsDegraded,
+		Status:  meta_v1.ConditionTrue,
+		Reason:  "RolloutsDegraded",
+		Message: strings.Join(messages, "; "),
+	}
+}
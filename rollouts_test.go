@@ -0,0 +1,142 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	apps_v1 "k8s.io/api/apps/v1"
+	core_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestRolloutTracker(t *testing.T) {
+	start := time.Date(2019, 10, 1, 12, 0, 0, 0, time.UTC)
+	controller := true
+	pod := func(name, hash string, created time.Time, ready bool) *core_v1.Pod {
+		p := newTestPod("web", name, core_v1.PodRunning, created)
+		p.Labels = map[string]string{"pod-template-hash": hash}
+		p.OwnerReferences = []meta_v1.OwnerReference{{Kind: "ReplicaSet", Name: "frontend-" + hash, Controller: &controller}}
+		status := core_v1.ConditionFalse
+		if ready {
+			status = core_v1.ConditionTrue
+		}
+		p.Status.Conditions = []core_v1.PodCondition{{Type: core_v1.PodReady, Status: status}}
+		return &p
+	}
+
+	rollouts := NewRolloutTracker(10*time.Minute, nil, nil)
+	old := []*core_v1.Pod{pod("a", "v1", start, true), pod("b", "v1", start, true)}
+	require.Empty(t, rollouts.Update(old, start))
+
+	crashing := pod("c", "v2", start.Add(time.Minute), false)
+	crashing.Status.ContainerStatuses = []core_v1.ContainerStatus{{
+		RestartCount: 3,
+		State:        core_v1.ContainerState{Waiting: &core_v1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}},
+	}}
+	status := rollouts.Update(append(old, crashing), start.Add(15*time.Minute))
+	require.Len(t, status, 1)
+	require.Equal(t, "web/Deployment/frontend", status[0].Workload)
+	require.Equal(t, "frontend-v1", status[0].PreviousRevision)
+	require.True(t, status[0].Stalled)
+	require.True(t, status[0].Unhealthy)
+	require.Equal(t, int32(3), status[0].New.Restarts)
+	require.Equal(t, int32(2), status[0].Previous.ReadyPods)
+
+	done := []*core_v1.Pod{pod("c", "v2", start.Add(time.Minute), true), pod("d", "v2", start.Add(2*time.Minute), true)}
+	status = rollouts.Update(done, start.Add(20*time.Minute))
+	require.NotNil(t, status[0].Completed)
+	require.False(t, status[0].Stalled)
+	require.Equal(t, int64(19*60), status[0].DurationSeconds)
+	require.Equal(t, int32(2), status[0].Previous.Pods)
+}
+
+func TestRolloutTrackerRevisionFromWorkloads(t *testing.T) {
+	start := time.Date(2019, 10, 1, 12, 0, 0, 0, time.UTC)
+	controller := true
+	replicaSets, statefulSets := cache.NewStore(cache.MetaNamespaceKeyFunc), cache.NewStore(cache.MetaNamespaceKeyFunc)
+	for revision, hash := range map[string]string{"1": "v1", "2": "v2"} {
+		replicaSets.Add(&apps_v1.ReplicaSet{ObjectMeta: meta_v1.ObjectMeta{
+			Namespace:       "web",
+			Name:            "frontend-" + hash,
+			Annotations:     map[string]string{deploymentRevisionAnnotation: revision},
+			OwnerReferences: []meta_v1.OwnerReference{{Kind: "Deployment", Name: "frontend", Controller: &controller}},
+		}})
+	}
+	statefulSets.Add(&apps_v1.StatefulSet{
+		ObjectMeta: meta_v1.ObjectMeta{Namespace: "db", Name: "postgres"},
+		Status:     apps_v1.StatefulSetStatus{CurrentRevision: "postgres-r1", UpdateRevision: "postgres-r2"},
+	})
+	deploymentPod := func(name, hash string, created time.Time) *core_v1.Pod {
+		p := newTestPod("web", name, core_v1.PodRunning, created)
+		p.Labels = map[string]string{"pod-template-hash": hash}
+		p.OwnerReferences = []meta_v1.OwnerReference{{Kind: "ReplicaSet", Name: "frontend-" + hash, Controller: &controller}}
+		return &p
+	}
+	statefulSetPod := func(name, revision string, created time.Time) *core_v1.Pod {
+		p := newTestPod("db", name, core_v1.PodRunning, created)
+		p.Labels = map[string]string{apps_v1.StatefulSetRevisionLabel: revision}
+		p.OwnerReferences = []meta_v1.OwnerReference{{Kind: "StatefulSet", Name: "postgres", Controller: &controller}}
+		return &p
+	}
+
+	rollouts := NewRolloutTracker(10*time.Minute, replicaSets, statefulSets)
+	pods := []*core_v1.Pod{
+		deploymentPod("a", "v1", start), deploymentPod("b", "v2", start.Add(time.Minute)),
+		statefulSetPod("postgres-0", "postgres-r1", start), statefulSetPod("postgres-1", "postgres-r2", start.Add(time.Minute)),
+	}
+	rollouts.Update(pods, start.Add(2*time.Minute))
+
+	// old pods replaced after the new ones were created do not flip the revision back
+	replaced := []*core_v1.Pod{
+		deploymentPod("c", "v1", start.Add(3*time.Minute)), deploymentPod("b", "v2", start.Add(time.Minute)),
+		statefulSetPod("postgres-0", "postgres-r1", start.Add(3*time.Minute)), statefulSetPod("postgres-1", "postgres-r2", start.Add(time.Minute)),
+	}
+	status := rollouts.Update(replaced, start.Add(4*time.Minute))
+	require.Len(t, status, 2)
+	require.Equal(t, "db/StatefulSet/postgres", status[0].Workload)
+	require.Equal(t, "postgres-r2", status[0].Revision)
+	require.Equal(t, "web/Deployment/frontend", status[1].Workload)
+	require.Equal(t, "frontend-v2", status[1].Revision)
+	require.Equal(t, int32(1), status[1].New.Pods)
+}
+
+func TestRolloutTrackerRollback(t *testing.T) {
+	start := time.Date(2019, 10, 1, 12, 0, 0, 0, time.UTC)
+	controller := true
+	replicaSets := cache.NewStore(cache.MetaNamespaceKeyFunc)
+	replicaSet := func(hash, revision string) *apps_v1.ReplicaSet {
+		return &apps_v1.ReplicaSet{ObjectMeta: meta_v1.ObjectMeta{
+			Namespace:       "web",
+			Name:            "frontend-" + hash,
+			Annotations:     map[string]string{deploymentRevisionAnnotation: revision},
+			OwnerReferences: []meta_v1.OwnerReference{{Kind: "Deployment", Name: "frontend", Controller: &controller}},
+		}}
+	}
+	replicaSets.Add(replicaSet("v1", "1"))
+	replicaSets.Add(replicaSet("v2", "2"))
+	pod := func(name, hash string, created time.Time) *core_v1.Pod {
+		p := newTestPod("web", name, core_v1.PodRunning, created)
+		p.Labels = map[string]string{"pod-template-hash": hash}
+		p.OwnerReferences = []meta_v1.OwnerReference{{Kind: "ReplicaSet", Name: "frontend-" + hash, Controller: &controller}}
+		p.Status.Conditions = []core_v1.PodCondition{{Type: core_v1.PodReady, Status: core_v1.ConditionFalse}}
+		return &p
+	}
+	// v2 is being rolled out next to the v1 pods running for hours
+	pods := []*core_v1.Pod{pod("a", "v1", start.Add(-3*time.Hour)), pod("b", "v2", start)}
+
+	rollouts := NewRolloutTracker(10*time.Minute, replicaSets, nil)
+	status := rollouts.Update(pods, start.Add(time.Minute))
+	require.Equal(t, "frontend-v2", status[0].Revision)
+	require.Equal(t, start, status[0].Started.Time)
+
+	// rolling back makes v1 the highest revision again
+	replicaSets.Update(replicaSet("v1", "3"))
+	status = rollouts.Update(pods, start.Add(2*time.Minute))
+	require.Len(t, status, 1)
+	require.Equal(t, "frontend-v1", status[0].Revision)
+	require.Equal(t, "frontend-v2", status[0].PreviousRevision)
+	require.Equal(t, start.Add(time.Minute), status[0].Started.Time)
+	require.False(t, status[0].Stalled)
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/jayapriya90/k8s-pod-monitor/v1alpha1"
+	core_v1 "k8s.io/api/core/v1"
+)
+
+// schedulingCategories map fragments of scheduler messages to the reported
+// reason. The first matching fragment wins
+var schedulingCategories = []struct {
+	fragment string
+	reason   string
+}{
+	{"insufficient cpu", "InsufficientCPU"},
+	{"insufficient memory", "InsufficientMemory"},
+	{"insufficient pods", "InsufficientPods"},
+	{"insufficient ephemeral-storage", "InsufficientEphemeralStorage"},
+	{"insufficient", "InsufficientResource"},
+	{"taint", "UntoleratedTaint"},
+	{"node affinity/selector", "NodeAffinity"},
+	{"node selector", "NodeAffinity"},
+	{"volume node affinity", "Volume"},
+	{"anti-affinity", "PodAffinity"},
+	{"pod affinity", "PodAffinity"},
+	{"topology spread", "TopologySpread"},
+	{"persistentvolumeclaim", "Volume"},
+	{"volume", "Volume"},
+	{"free ports", "HostPort"},
+	{"unschedulable", "NodeUnschedulable"},
+	{"not ready", "NodeNotReady"},
+}
+
+// reasonOther is reported for scheduler messages that match no category
+const reasonOther = "Other"
+
+// schedulingReasons normalizes the message of a PodScheduled=False condition,
+// like "0/5 nodes are available: 3 Insufficient cpu, 2 node(s) had taint {a: b},
+// that the pod didn't tolerate.", into the set of reasons it names
+func schedulingReasons(message string) []string {
+	// the preemption attempt repeats the node reasons
+	if i := strings.Index(message, ". preemption:"); i >= 0 {
+		message = message[:i]
+	}
+	if i := strings.Index(message, "are available:"); i >= 0 {
+		message = message[i+len("are available:"):]
+	}
+	found := make(map[string]bool)
+	for _, part := range splitOutsideBraces(strings.TrimSuffix(strings.TrimSpace(message), ".")) {
+		part = strings.ToLower(strings.TrimSpace(part))
+		if part == "" || strings.HasPrefix(part, "that the pod didn't tolerate") {
+			continue
+		}
+		reason := reasonOther
+		for _, category := range schedulingCategories {
+			if strings.Contains(part, category.fragment) {
+				reason = category.reason
+				break
+			}
+		}
+		found[reason] = true
+	}
+	reasons := make([]string, 0, len(found))
+	for reason := range found {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+	return reasons
+}
+
+// splitOutsideBraces splits on commas that are not part of a taint or label in braces
+func splitOutsideBraces(s string) []string {
+	var parts []string
+	depth, start := 0, 0
+	for i, r := range s {
+		switch r {
+		case '{':
+			depth++
+		case '}':
+			depth--
+		case ',':
+			if depth == 0 {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// unschedulableMessage returns the message of the PodScheduled=False condition of a pending pod
+func unschedulableMessage(pod *core_v1.Pod) (string, bool) {
+	if pod.Status.Phase != core_v1.PodPending {
+		return "", false
+	}
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == core_v1.PodScheduled && condition.Status == core_v1.ConditionFalse && condition.Reason == core_v1.PodReasonUnschedulable {
+			return condition.Message, true
+		}
+	}
+	return "", false
+}
+
+// schedulingAccumulator counts unschedulable pods per reason, keeping the
+// first message seen as an example
+type schedulingAccumulator map[string]*v1alpha1.SchedulingReason
+
+func (a schedulingAccumulator) add(reason, message string) {
+	entry, exists := a[reason]
+	if !exists {
+		entry = &v1alpha1.SchedulingReason{Reason: reason, Example: message}
+		a[reason] = entry
+	}
+	entry.Pods++
+}
+
+// reasons returns the counts ordered by pods, most first
+func (a schedulingAccumulator) reasons() []v1alpha1.SchedulingReason {
+	reasons := make([]v1alpha1.SchedulingReason, 0, len(a))
+	for _, reason := range a {
+		reasons = append(reasons, *reason)
+	}
+	sort.Slice(reasons, func(i, j int) bool {
+		if reasons[i].Pods != reasons[j].Pods {
+			return reasons[i].Pods > reasons[j].Pods
+		}
+		return reasons[i].Reason < reasons[j].Reason
+	})
+	return reasons
+}
+
+// schedulingStatus counts the pending pods the scheduler failed to place, in
+// total and per namespace. A pod is counted once for every reason it names
+func schedulingStatus(pods map[string]*core_v1.Pod) *v1alpha1.SchedulingStatus {
+	status := &v1alpha1.SchedulingStatus{}
+	total := schedulingAccumulator{}
+	namespaces := make(map[string]*v1alpha1.NamespaceScheduling)
+	namespaceReasons := make(map[string]schedulingAccumulator)
+	for _, pod := range pods {
+		message, unschedulable := unschedulableMessage(pod)
+		if !unschedulable {
+			continue
+		}
+		ns, exists := namespaces[pod.Namespace]
+		if !exists {
+			ns = &v1alpha1.NamespaceScheduling{Namespace: pod.Namespace}
+			namespaces[pod.Namespace] = ns
+			namespaceReasons[pod.Namespace] = schedulingAccumulator{}
+		}
+		status.UnschedulablePods++
+		ns.UnschedulablePods++
+		for _, reason := range schedulingReasons(message) {
+			total.add(reason, message)
+			namespaceReasons[pod.Namespace].add(reason, message)
+		}
+	}
+	status.Reasons = total.reasons()
+	for name, ns := range namespaces {
+		ns.Reasons = namespaceReasons[name].reasons()
+		status.Namespaces = append(status.Namespaces, *ns)
+	}
+	sort.Slice(status.Namespaces, func(i, j int) bool {
+		return status.Namespaces[i].Namespace < status.Namespaces[j].Namespace
+	})
+	return status
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jayapriya90/k8s-pod-monitor/v1alpha1"
+	"github.com/stretchr/testify/require"
+	core_v1 "k8s.io/api/core/v1"
+)
+
+func TestSchedulingReasons(t *testing.T) {
+	message := "0/6 nodes are available: 1 node(s) had untolerated taint {node-role.kubernetes.io/control-plane: }, " +
+		"3 Insufficient cpu, 2 node(s) didn't match Pod's node affinity/selector. preemption: 0/6 nodes are available: " +
+		"3 No preemption victims found for incoming pod, 3 Preemption is not helpful for scheduling."
+	require.Equal(t, []string{"InsufficientCPU", "NodeAffinity", "UntoleratedTaint"}, schedulingReasons(message))
+	require.Equal(t, []string{"Volume"}, schedulingReasons("pod has unbound immediate PersistentVolumeClaims"))
+}
+
+func TestSchedulingStatus(t *testing.T) {
+	type testPod struct {
+		namespace string
+		phase     core_v1.PodPhase
+		status    core_v1.ConditionStatus
+		reason    string
+		message   string
+	}
+	const (
+		cpu    = "0/3 nodes are available: 3 Insufficient cpu."
+		memory = "0/3 nodes are available: 3 Insufficient memory."
+		taint  = "0/3 nodes are available: 1 Insufficient cpu, 2 node(s) had taint {dedicated: gpu}, that the pod didn't tolerate."
+		volume = "pod has unbound immediate PersistentVolumeClaims"
+	)
+	unschedulable := func(namespace, message string) testPod {
+		return testPod{namespace, core_v1.PodPending, core_v1.ConditionFalse, core_v1.PodReasonUnschedulable, message}
+	}
+	reason := func(reason string, pods int32) v1alpha1.SchedulingReason {
+		return v1alpha1.SchedulingReason{Reason: reason, Pods: pods}
+	}
+
+	for _, test := range []struct {
+		name     string
+		pods     []testPod
+		expected v1alpha1.SchedulingStatus
+	}{{
+		name: "only unschedulable pending pods count",
+		pods: []testPod{
+			{"web", core_v1.PodPending, core_v1.ConditionTrue, "", ""},
+			{"web", core_v1.PodPending, core_v1.ConditionFalse, "SchedulingGated", "scheduling is blocked"},
+			{"web", core_v1.PodFailed, core_v1.ConditionFalse, core_v1.PodReasonUnschedulable, cpu},
+		},
+		expected: v1alpha1.SchedulingStatus{Reasons: []v1alpha1.SchedulingReason{}},
+	}, {
+		name: "one namespace",
+		pods: []testPod{unschedulable("web", cpu), unschedulable("web", taint)},
+		expected: v1alpha1.SchedulingStatus{
+			UnschedulablePods: 2,
+			Reasons:           []v1alpha1.SchedulingReason{reason("InsufficientCPU", 2), reason("UntoleratedTaint", 1)},
+			Namespaces: []v1alpha1.NamespaceScheduling{{
+				Namespace:         "web",
+				UnschedulablePods: 2,
+				Reasons:           []v1alpha1.SchedulingReason{reason("InsufficientCPU", 2), reason("UntoleratedTaint", 1)},
+			}},
+		},
+	}, {
+		name: "reasons per namespace",
+		pods: []testPod{
+			unschedulable("web", cpu), unschedulable("web", taint),
+			unschedulable("batch", memory), unschedulable("batch", volume), unschedulable("batch", cpu),
+		},
+		expected: v1alpha1.SchedulingStatus{
+			UnschedulablePods: 5,
+			Reasons: []v1alpha1.SchedulingReason{
+				reason("InsufficientCPU", 3), reason("InsufficientMemory", 1), reason("UntoleratedTaint", 1), reason("Volume", 1),
+			},
+			Namespaces: []v1alpha1.NamespaceScheduling{{
+				Namespace:         "batch",
+				UnschedulablePods: 3,
+				Reasons:           []v1alpha1.SchedulingReason{reason("InsufficientCPU", 1), reason("InsufficientMemory", 1), reason("Volume", 1)},
+			}, {
+				Namespace:         "web",
+				UnschedulablePods: 2,
+				Reasons:           []v1alpha1.SchedulingReason{reason("InsufficientCPU", 2), reason("UntoleratedTaint", 1)},
+			}},
+		},
+	}} {
+		t.Run(test.name, func(t *testing.T) {
+			pods := make(map[string]*core_v1.Pod)
+			for i, p := range test.pods {
+				pod := newTestPod(p.namespace, string(rune('a'+i)), p.phase, time.Now())
+				pod.Status.Conditions = []core_v1.PodCondition{{Type: core_v1.PodScheduled, Status: p.status, Reason: p.reason, Message: p.message}}
+				pods[pod.Namespace+"/"+pod.Name] = &pod
+			}
+
+			status := schedulingStatus(pods)
+			// the example is the message of any of the pods failing for a reason
+			clearExamples := func(reasons []v1alpha1.SchedulingReason) {
+				for i := range reasons {
+					require.NotEmpty(t, reasons[i].Example)
+					reasons[i].Example = ""
+				}
+			}
+			clearExamples(status.Reasons)
+			for _, ns := range status.Namespaces {
+				clearExamples(ns.Reasons)
+			}
+			require.Equal(t, test.expected, *status)
+		})
+	}
+}
@@ -0,0 +1,330 @@
+package main
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/jayapriya90/k8s-pod-monitor/v1alpha1"
+	core_v1 "k8s.io/api/core/v1"
+)
+
+// Pod Security Standards levels
+const (
+	levelPrivileged = "privileged"
+	levelBaseline   = "baseline"
+	levelRestricted = "restricted"
+)
+
+// securityCheck is a Pod Security Standards check. Check names follow the
+// ones of the pod security admission controller
+type securityCheck struct {
+	name  string
+	level string
+	// fails reports whether the pod violates the check
+	fails func(pod *core_v1.Pod, containers []securityContainer) bool
+}
+
+// securityContainer is the part of an app, init or ephemeral container the checks look at
+type securityContainer struct {
+	name            string
+	securityContext *core_v1.SecurityContext
+	ports           []core_v1.ContainerPort
+}
+
+func podSecurityContainers(pod *core_v1.Pod) []securityContainer {
+	var containers []securityContainer
+	for _, list := range [][]core_v1.Container{pod.Spec.InitContainers, pod.Spec.Containers} {
+		for _, container := range list {
+			containers = append(containers, securityContainer{container.Name, container.SecurityContext, container.Ports})
+		}
+	}
+	for _, container := range pod.Spec.EphemeralContainers {
+		containers = append(containers, securityContainer{container.Name, container.SecurityContext, container.Ports})
+	}
+	return containers
+}
+
+// anyContainer reports whether fails holds for the security context of any container
+func anyContainer(containers []securityContainer, fails func(sc *core_v1.SecurityContext) bool) bool {
+	for _, container := range containers {
+		if container.securityContext != nil && fails(container.securityContext) {
+			return true
+		}
+	}
+	return false
+}
+
+// baselineCapabilities are the capabilities containers may add at the baseline level
+var baselineCapabilities = map[core_v1.Capability]bool{
+	"AUDIT_WRITE": true, "CHOWN": true, "DAC_OVERRIDE": true, "FOWNER": true, "FSETID": true, "KILL": true, "MKNOD": true,
+	"NET_BIND_SERVICE": true, "SETFCAP": true, "SETGID": true, "SETPCAP": true, "SETUID": true, "SYS_CHROOT": true,
+}
+
+// safeSysctls are the sysctls pods may set at the baseline level
+var safeSysctls = map[string]bool{
+	"kernel.shm_rmid_forced":              true,
+	"net.ipv4.ip_local_port_range":        true,
+	"net.ipv4.ip_unprivileged_port_start": true,
+	"net.ipv4.tcp_syncookies":             true,
+	"net.ipv4.ping_group_range":           true,
+}
+
+// seccomp and AppArmor profiles are set with annotations by the API version this is built against
+const (
+	seccompPodAnnotation             = "seccomp.security.alpha.kubernetes.io/pod"
+	seccompContainerAnnotationPrefix = "container.seccomp.security.alpha.kubernetes.io/"
+	appArmorAnnotationPrefix         = "container.apparmor.security.beta.kubernetes.io/"
+)
+
+// seccompProfiles returns the seccomp profile in effect for every container
+func seccompProfiles(pod *core_v1.Pod, containers []securityContainer) []string {
+	profiles := make([]string, 0, len(containers))
+	for _, container := range containers {
+		profile, exists := pod.Annotations[seccompContainerAnnotationPrefix+container.name]
+		if !exists {
+			profile = pod.Annotations[seccompPodAnnotation]
+		}
+		profiles = append(profiles, profile)
+	}
+	return profiles
+}
+
+// securityChecks are the checks of the baseline and restricted levels
+var securityChecks = []securityCheck{
+	{"hostNamespaces", levelBaseline, func(pod *core_v1.Pod, _ []securityContainer) bool {
+		return pod.Spec.HostNetwork || pod.Spec.HostPID || pod.Spec.HostIPC
+	}},
+	{"privileged", levelBaseline, func(_ *core_v1.Pod, containers []securityContainer) bool {
+		return anyContainer(containers, func(sc *core_v1.SecurityContext) bool {
+			return sc.Privileged != nil && *sc.Privileged
+		})
+	}},
+	{"capabilities_baseline", levelBaseline, func(_ *core_v1.Pod, containers []securityContainer) bool {
+		return anyContainer(containers, func(sc *core_v1.SecurityContext) bool {
+			if sc.Capabilities == nil {
+				return false
+			}
+			for _, capability := range sc.Capabilities.Add {
+				if !baselineCapabilities[capability] {
+					return true
+				}
+			}
+			return false
+		})
+	}},
+	{"hostPathVolumes", levelBaseline, func(pod *core_v1.Pod, _ []securityContainer) bool {
+		for _, volume := range pod.Spec.Volumes {
+			if volume.HostPath != nil {
+				return true
+			}
+		}
+		return false
+	}},
+	{"hostPorts", levelBaseline, func(_ *core_v1.Pod, containers []securityContainer) bool {
+		for _, container := range containers {
+			for _, port := range container.ports {
+				if port.HostPort != 0 {
+					return true
+				}
+			}
+		}
+		return false
+	}},
+	{"appArmorProfile", levelBaseline, func(pod *core_v1.Pod, _ []securityContainer) bool {
+		for key, profile := range pod.Annotations {
+			if strings.HasPrefix(key, appArmorAnnotationPrefix) && profile != "runtime/default" && !strings.HasPrefix(profile, "localhost/") {
+				return true
+			}
+		}
+		return false
+	}},
+	{"seLinuxOptions", levelBaseline, func(pod *core_v1.Pod, containers []securityContainer) bool {
+		invalid := func(options *core_v1.SELinuxOptions) bool {
+			if options == nil {
+				return false
+			}
+			switch options.Type {
+			case "", "container_t", "container_init_t", "container_kvm_t":
+			default:
+				return true
+			}
+			return options.User != "" || options.Role != ""
+		}
+		if pod.Spec.SecurityContext != nil && invalid(pod.Spec.SecurityContext.SELinuxOptions) {
+			return true
+		}
+		return anyContainer(containers, func(sc *core_v1.SecurityContext) bool {
+			return invalid(sc.SELinuxOptions)
+		})
+	}},
+	{"procMount", levelBaseline, func(_ *core_v1.Pod, containers []securityContainer) bool {
+		return anyContainer(containers, func(sc *core_v1.SecurityContext) bool {
+			return sc.ProcMount != nil && *sc.ProcMount != core_v1.DefaultProcMount
+		})
+	}},
+	{"seccompProfile_baseline", levelBaseline, func(pod *core_v1.Pod, containers []securityContainer) bool {
+		for _, profile := range seccompProfiles(pod, containers) {
+			if profile == "unconfined" {
+				return true
+			}
+		}
+		return false
+	}},
+	{"sysctls", levelBaseline, func(pod *core_v1.Pod, _ []securityContainer) bool {
+		if pod.Spec.SecurityContext == nil {
+			return false
+		}
+		for _, sysctl := range pod.Spec.SecurityContext.Sysctls {
+			if !safeSysctls[sysctl.Name] {
+				return true
+			}
+		}
+		return false
+	}},
+	{"restrictedVolumes", levelRestricted, func(pod *core_v1.Pod, _ []securityContainer) bool {
+		for _, volume := range pod.Spec.Volumes {
+			source := volume.VolumeSource
+			if source.ConfigMap == nil && source.CSI == nil && source.DownwardAPI == nil && source.EmptyDir == nil &&
+				source.PersistentVolumeClaim == nil && source.Projected == nil && source.Secret == nil {
+				return true
+			}
+		}
+		return false
+	}},
+	{"allowPrivilegeEscalation", levelRestricted, func(_ *core_v1.Pod, containers []securityContainer) bool {
+		for _, container := range containers {
+			sc := container.securityContext
+			if sc == nil || sc.AllowPrivilegeEscalation == nil || *sc.AllowPrivilegeEscalation {
+				return true
+			}
+		}
+		return false
+	}},
+	{"runAsNonRoot", levelRestricted, func(pod *core_v1.Pod, containers []securityContainer) bool {
+		podNonRoot := pod.Spec.SecurityContext != nil && pod.Spec.SecurityContext.RunAsNonRoot != nil && *pod.Spec.SecurityContext.RunAsNonRoot
+		for _, container := range containers {
+			sc := container.securityContext
+			if sc != nil && sc.RunAsNonRoot != nil {
+				if !*sc.RunAsNonRoot {
+					return true
+				}
+				continue
+			}
+			if !podNonRoot {
+				return true
+			}
+		}
+		return false
+	}},
+	{"runAsUser", levelRestricted, func(pod *core_v1.Pod, containers []securityContainer) bool {
+		if pod.Spec.SecurityContext != nil && pod.Spec.SecurityContext.RunAsUser != nil && *pod.Spec.SecurityContext.RunAsUser == 0 {
+			return true
+		}
+		return anyContainer(containers, func(sc *core_v1.SecurityContext) bool {
+			return sc.RunAsUser != nil && *sc.RunAsUser == 0
+		})
+	}},
+	{"seccompProfile_restricted", levelRestricted, func(pod *core_v1.Pod, containers []securityContainer) bool {
+		for _, profile := range seccompProfiles(pod, containers) {
+			if profile != "runtime/default" && profile != "docker/default" && !strings.HasPrefix(profile, "localhost/") {
+				return true
+			}
+		}
+		return false
+	}},
+	{"capabilities_restricted", levelRestricted, func(_ *core_v1.Pod, containers []securityContainer) bool {
+		for _, container := range containers {
+			sc := container.securityContext
+			if sc == nil || sc.Capabilities == nil {
+				return true
+			}
+			dropsAll := false
+			for _, capability := range sc.Capabilities.Drop {
+				if capability == "ALL" {
+					dropsAll = true
+				}
+			}
+			if !dropsAll {
+				return true
+			}
+			for _, capability := range sc.Capabilities.Add {
+				if capability != "NET_BIND_SERVICE" {
+					return true
+				}
+			}
+		}
+		return false
+	}},
+}
+
+// PodSecurity is the most restrictive level a pod satisfies and the checks it fails
+type PodSecurity struct {
+	Level  string   `json:"level"`
+	Failed []string `json:"failed,omitempty"`
+}
+
+// evaluatePodSecurity runs the baseline and restricted checks against a pod
+func evaluatePodSecurity(pod *core_v1.Pod) PodSecurity {
+	containers := podSecurityContainers(pod)
+	result := PodSecurity{Level: levelRestricted}
+	for _, check := range securityChecks {
+		if !check.fails(pod, containers) {
+			continue
+		}
+		result.Failed = append(result.Failed, check.name)
+		if check.level == levelBaseline {
+			result.Level = levelPrivileged
+		} else if result.Level == levelRestricted {
+			result.Level = levelBaseline
+		}
+	}
+	return result
+}
+
+// securityStatus counts per namespace the running and pending pods that
+// violate the baseline and restricted levels, and the pods failing each check
+func securityStatus(pods map[string]*core_v1.Pod) []v1alpha1.NamespaceSecurity {
+	namespaces := make(map[string]*v1alpha1.NamespaceSecurity)
+	checks := make(map[string]map[string]int32)
+	for _, pod := range pods {
+		if pod.Status.Phase != core_v1.PodRunning && pod.Status.Phase != core_v1.PodPending {
+			continue
+		}
+		ns, exists := namespaces[pod.Namespace]
+		if !exists {
+			ns = &v1alpha1.NamespaceSecurity{Namespace: pod.Namespace}
+			namespaces[pod.Namespace] = ns
+			checks[pod.Namespace] = make(map[string]int32)
+		}
+		ns.Pods++
+		result := evaluatePodSecurity(pod)
+		if result.Level == levelPrivileged {
+			ns.BaselineViolations++
+		}
+		if result.Level != levelRestricted {
+			ns.RestrictedViolations++
+		}
+		for _, check := range result.Failed {
+			checks[pod.Namespace][check]++
+		}
+	}
+
+	levels := make(map[string]string, len(securityChecks))
+	for _, check := range securityChecks {
+		levels[check.name] = check.level
+	}
+	status := make([]v1alpha1.NamespaceSecurity, 0, len(namespaces))
+	for name, ns := range namespaces {
+		for check, pods := range checks[name] {
+			ns.Checks = append(ns.Checks, v1alpha1.SecurityCheckCount{Check: check, Level: levels[check], Pods: pods})
+		}
+		sort.Slice(ns.Checks, func(i, j int) bool {
+			return ns.Checks[i].Check < ns.Checks[j].Check
+		})
+		status = append(status, *ns)
+	}
+	sort.Slice(status, func(i, j int) bool {
+		return status[i].Namespace < status[j].Namespace
+	})
+	return status
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	core_v1 "k8s.io/api/core/v1"
+)
+
+func TestEvaluatePodSecurity(t *testing.T) {
+	yes, no, root := true, false, int64(0)
+	restricted := newTestPod("web", "restricted", core_v1.PodRunning, time.Now())
+	restricted.Annotations = map[string]string{seccompPodAnnotation: "runtime/default"}
+	restricted.Spec.SecurityContext = &core_v1.PodSecurityContext{RunAsNonRoot: &yes}
+	restricted.Spec.Containers = []core_v1.Container{{Name: "app", SecurityContext: &core_v1.SecurityContext{
+		AllowPrivilegeEscalation: &no,
+		Capabilities:             &core_v1.Capabilities{Drop: []core_v1.Capability{"ALL"}},
+	}}}
+	require.Equal(t, PodSecurity{Level: levelRestricted}, evaluatePodSecurity(&restricted))
+
+	baseline := newTestPod("web", "baseline", core_v1.PodRunning, time.Now())
+	baseline.Spec.Containers = []core_v1.Container{{Name: "app", SecurityContext: &core_v1.SecurityContext{RunAsUser: &root}}}
+	result := evaluatePodSecurity(&baseline)
+	require.Equal(t, levelBaseline, result.Level)
+	require.Contains(t, result.Failed, "runAsUser")
+
+	privileged := newTestPod("web", "privileged", core_v1.PodRunning, time.Now())
+	privileged.Spec.HostNetwork = true
+	privileged.Spec.Containers = []core_v1.Container{{Name: "app", SecurityContext: &core_v1.SecurityContext{Privileged: &yes}}}
+	result = evaluatePodSecurity(&privileged)
+	require.Equal(t, levelPrivileged, result.Level)
+	require.Contains(t, result.Failed, "hostNamespaces")
+	require.Contains(t, result.Failed, "privileged")
+
+	status := securityStatus(map[string]*core_v1.Pod{"a": &restricted, "b": &baseline, "c": &privileged})
+	require.Len(t, status, 1)
+	require.Equal(t, int32(3), status[0].Pods)
+	require.Equal(t, int32(1), status[0].BaselineViolations)
+	require.Equal(t, int32(2), status[0].RestrictedViolations)
+}
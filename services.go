@@ -0,0 +1,108 @@
+package main
+
+import (
+	"sort"
+
+	"github.com/jayapriya90/k8s-pod-monitor/v1alpha1"
+	core_v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// servicesStatus joins the running pods with Services and Endpoints. It returns
+// the running pods listed as not ready addresses of a Service, and the Services
+// whose selector matches no running pod. Services without selector are skipped,
+// their endpoints are managed outside the cluster
+func servicesStatus(pods []*core_v1.Pod, services, endpoints []interface{}) *v1alpha1.ServicesStatus {
+	running := make(map[string]*core_v1.Pod)
+	byNamespace := make(map[string][]*core_v1.Pod)
+	for _, pod := range pods {
+		if pod.Status.Phase != core_v1.PodRunning {
+			continue
+		}
+		running[pod.Namespace+"/"+pod.Name] = pod
+		byNamespace[pod.Namespace] = append(byNamespace[pod.Namespace], pod)
+	}
+
+	status := &v1alpha1.ServicesStatus{}
+	for _, obj := range services {
+		service, ok := obj.(*core_v1.Service)
+		if !ok || len(service.Spec.Selector) == 0 || service.Spec.Type == core_v1.ServiceTypeExternalName {
+			continue
+		}
+		selector := labels.SelectorFromSet(service.Spec.Selector)
+		matched := false
+		for _, pod := range byNamespace[service.Namespace] {
+			if selector.Matches(labels.Set(pod.Labels)) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			status.Unmatched = append(status.Unmatched, v1alpha1.UnmatchedService{
+				Namespace: service.Namespace,
+				Name:      service.Name,
+				Selector:  selector.String(),
+			})
+		}
+	}
+	for _, obj := range endpoints {
+		endpoint, ok := obj.(*core_v1.Endpoints)
+		if !ok {
+			continue
+		}
+		seen := make(map[string]bool)
+		for _, subset := range endpoint.Subsets {
+			for _, address := range subset.NotReadyAddresses {
+				if address.TargetRef == nil || address.TargetRef.Kind != "Pod" || seen[address.TargetRef.Name] {
+					continue
+				}
+				pod, isRunning := running[endpoint.Namespace+"/"+address.TargetRef.Name]
+				if !isRunning {
+					continue
+				}
+				seen[pod.Name] = true
+				status.NotReady = append(status.NotReady, v1alpha1.NotReadyEndpoint{
+					Namespace: endpoint.Namespace,
+					Service:   endpoint.Name,
+					Pod:       pod.Name,
+					Reason:    notReadyReason(pod),
+				})
+			}
+		}
+	}
+
+	sort.Slice(status.Unmatched, func(i, j int) bool {
+		a, b := status.Unmatched[i], status.Unmatched[j]
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return a.Name < b.Name
+	})
+	sort.Slice(status.NotReady, func(i, j int) bool {
+		a, b := status.NotReady[i], status.NotReady[j]
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		if a.Service != b.Service {
+			return a.Service < b.Service
+		}
+		return a.Pod < b.Pod
+	})
+	return status
+}
+
+// notReadyReason explains why a running pod is not ready, from the Ready
+// condition or the first container that is not ready
+func notReadyReason(pod *core_v1.Pod) string {
+	for _, status := range pod.Status.ContainerStatuses {
+		if !status.Ready {
+			return "container " + status.Name + " not ready"
+		}
+	}
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == core_v1.PodReady && condition.Status != core_v1.ConditionTrue && condition.Reason != "" {
+			return condition.Reason
+		}
+	}
+	return ""
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jayapriya90/k8s-pod-monitor/v1alpha1"
+	"github.com/stretchr/testify/require"
+	core_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestServicesStatus(t *testing.T) {
+	web := newTestPod("web", "a", core_v1.PodRunning, time.Now())
+	web.Labels = map[string]string{"app": "web"}
+	web.Status.ContainerStatuses = []core_v1.ContainerStatus{{Name: "nginx", Ready: false}}
+	services := []interface{}{
+		&core_v1.Service{ObjectMeta: meta_v1.ObjectMeta{Namespace: "web", Name: "web"}, Spec: core_v1.ServiceSpec{Selector: map[string]string{"app": "web"}}},
+		&core_v1.Service{ObjectMeta: meta_v1.ObjectMeta{Namespace: "web", Name: "api"}, Spec: core_v1.ServiceSpec{Selector: map[string]string{"app": "api"}}},
+		&core_v1.Service{ObjectMeta: meta_v1.ObjectMeta{Namespace: "web", Name: "external"}},
+	}
+	endpoints := []interface{}{
+		&core_v1.Endpoints{
+			ObjectMeta: meta_v1.ObjectMeta{Namespace: "web", Name: "web"},
+			Subsets: []core_v1.EndpointSubset{{NotReadyAddresses: []core_v1.EndpointAddress{
+				{IP: "10.0.0.1", TargetRef: &core_v1.ObjectReference{Kind: "Pod", Name: "a"}},
+				{IP: "10.0.0.2", TargetRef: &core_v1.ObjectReference{Kind: "Pod", Name: "deleted"}},
+			}}},
+		},
+	}
+
+	status := servicesStatus([]*core_v1.Pod{&web}, services, endpoints)
+	require.Equal(t, []v1alpha1.NotReadyEndpoint{{Namespace: "web", Service: "web", Pod: "a", Reason: "container nginx not ready"}}, status.NotReady)
+	require.Equal(t, []v1alpha1.UnmatchedService{{Namespace: "web", Name: "api", Selector: "app=api"}}, status.Unmatched)
+}
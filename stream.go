@@ -0,0 +1,240 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+const (
+	// streamEventCounts is published every time the PodMonitor counts change
+	streamEventCounts = "counts"
+	// streamEventPod is published for every pod phase transition
+	streamEventPod = "pod"
+	// streamEventReset tells a resuming client that events after its
+	// Last-Event-ID are no longer in the history and were not replayed
+	streamEventReset = "reset"
+	// phaseDeleted is the transition target of deleted pods
+	phaseDeleted = "Deleted"
+	// streamHeartbeat keeps idle connections from being closed by proxies
+	streamHeartbeat = 30 * time.Second
+)
+
+// StreamEvent is a single message of the event stream
+type StreamEvent struct {
+	ID   uint64
+	Type string
+	Pod  *PodTransition
+}
+
+// PodTransition describes a pod moving from one phase to another
+type PodTransition struct {
+	Namespace string            `json:"namespace"`
+	Name      string            `json:"name"`
+	Owner     string            `json:"owner,omitempty"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	From      string            `json:"from,omitempty"`
+	To        string            `json:"to"`
+	Time      time.Time         `json:"time"`
+}
+
+// EventBroker fans events out to stream subscribers. It keeps a bounded
+// history so clients can resume from the Last-Event-ID they received, and
+// disconnects clients whose buffer is full instead of blocking the publisher
+type EventBroker struct {
+	mu          sync.Mutex
+	lastID      uint64
+	history     []StreamEvent
+	historySize int
+	bufferSize  int
+	subscribers map[chan StreamEvent]bool
+}
+
+// NewEventBroker returns a broker keeping historySize events and buffering
+// up to bufferSize events per subscriber
+func NewEventBroker(historySize, bufferSize int) *EventBroker {
+	return &EventBroker{
+		historySize: historySize,
+		bufferSize:  bufferSize,
+		subscribers: make(map[chan StreamEvent]bool),
+	}
+}
+
+// Publish assigns the next ID to the event and delivers it to every subscriber
+func (b *EventBroker) Publish(event StreamEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.lastID++
+	event.ID = b.lastID
+	b.history = append(b.history, event)
+	if len(b.history) > b.historySize {
+		b.history = b.history[len(b.history)-b.historySize:]
+	}
+	for ch := range b.subscribers {
+		select {
+		case ch <- event:
+		default:
+			// the client is not keeping up. Closing the channel ends its stream
+			// and the client can resume from the last event it received
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// Subscribe registers a new subscriber. It returns the events published after
+// lastID that are still in the history, the ID of the latest event and whether
+// the backlog is complete. The backlog is incomplete when events after lastID
+// already left the history or lastID is from before a restart of the broker
+func (b *EventBroker) Subscribe(lastID uint64) (chan StreamEvent, []StreamEvent, uint64, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	ch := make(chan StreamEvent, b.bufferSize)
+	b.subscribers[ch] = true
+	var backlog []StreamEvent
+	for _, event := range b.history {
+		if event.ID > lastID {
+			backlog = append(backlog, event)
+		}
+	}
+	complete := lastID == 0 || lastID == b.lastID || (lastID < b.lastID && len(backlog) == int(b.lastID-lastID))
+	return ch, backlog, b.lastID, complete
+}
+
+// Unsubscribe removes a subscriber that has not already been disconnected
+func (b *EventBroker) Unsubscribe(ch chan StreamEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.subscribers[ch] {
+		delete(b.subscribers, ch)
+		close(ch)
+	}
+}
+
+// handleStream serves /api/v1/stream?namespace=&label=&phase=&pods=true as
+// Server-Sent Events. Counts messages carry the same counts as /api/v1/counts
+// for the given filters and are only sent when those counts change. Pod
+// transition messages are only sent when pods=true
+func (s *APIServer) handleStream(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	filter, err := parsePodFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	withPods := r.URL.Query().Get("pods") == "true"
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = r.URL.Query().Get("lastEventId")
+	}
+	var lastID uint64
+	if lastEventID != "" {
+		if lastID, err = strconv.ParseUint(lastEventID, 10, 64); err != nil {
+			http.Error(w, fmt.Sprintf("invalid Last-Event-ID %q", lastEventID), http.StatusBadRequest)
+			return
+		}
+	}
+
+	events, backlog, latestID, complete := s.broker.Subscribe(lastID)
+	defer s.broker.Unsubscribe(events)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	// replay the missed pod transitions, then send the current counts as of the
+	// latest event so the client resumes after both
+	if !complete {
+		if err := writeStreamEvent(w, 0, streamEventReset, streamReset{LastEventID: lastID}); err != nil {
+			return
+		}
+	}
+	for _, event := range backlog {
+		if event.Type == streamEventPod && withPods && filter.matchesTransition(event.Pod) {
+			if err := writeStreamEvent(w, event.ID, event.Type, event.Pod); err != nil {
+				return
+			}
+		}
+	}
+	lastCounts := s.counts(filter, time.Now())
+	if err := writeStreamEvent(w, latestID, streamEventCounts, lastCounts); err != nil {
+		return
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		var err error
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			_, err = fmt.Fprint(w, ": heartbeat\n\n")
+		case event, open := <-events:
+			if !open {
+				log.Infof("Disconnecting slow stream client %s", r.RemoteAddr)
+				return
+			}
+			switch event.Type {
+			case streamEventCounts:
+				if counts := s.counts(filter, time.Now()); counts != lastCounts {
+					lastCounts = counts
+					err = writeStreamEvent(w, event.ID, event.Type, counts)
+				}
+			case streamEventPod:
+				if withPods && filter.matchesTransition(event.Pod) {
+					err = writeStreamEvent(w, event.ID, event.Type, event.Pod)
+				}
+			}
+		}
+		if err != nil {
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+// streamReset is the data of a reset message
+type streamReset struct {
+	LastEventID uint64 `json:"lastEventId"`
+}
+
+// writeStreamEvent writes a message. Messages with ID 0 leave the last event
+// ID of the client unchanged
+func writeStreamEvent(w http.ResponseWriter, id uint64, eventType string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if id != 0 {
+		if _, err = fmt.Fprintf(w, "id: %d\n", id); err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", eventType, data)
+	return err
+}
+
+func (f podFilter) matchesTransition(transition *PodTransition) bool {
+	if f.namespace != "" && f.namespace != transition.Namespace {
+		return false
+	}
+	if f.phase != "" && string(f.phase) != transition.To {
+		return false
+	}
+	return f.selector.Matches(labels.Set(transition.Labels))
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	core_v1 "k8s.io/api/core/v1"
+)
+
+func TestEventBrokerResumeAndSlowClients(t *testing.T) {
+	broker := NewEventBroker(2, 1)
+	for i := 0; i < 3; i++ {
+		broker.Publish(StreamEvent{Type: streamEventCounts})
+	}
+
+	// only the last two events are kept for resuming clients
+	events, backlog, latestID, complete := broker.Subscribe(0)
+	require.Equal(t, uint64(3), latestID)
+	require.True(t, complete)
+	require.Len(t, backlog, 2)
+	require.Equal(t, uint64(2), backlog[0].ID)
+
+	// the second event overflows the client buffer and disconnects it
+	broker.Publish(StreamEvent{Type: streamEventCounts})
+	broker.Publish(StreamEvent{Type: streamEventCounts})
+	event, open := <-events
+	require.True(t, open)
+	require.Equal(t, uint64(4), event.ID)
+	_, open = <-events
+	require.False(t, open)
+	broker.Unsubscribe(events)
+
+	for lastID, want := range map[uint64]bool{3: true, 5: true, 2: false, 9: false} {
+		events, _, _, complete = broker.Subscribe(lastID)
+		require.Equal(t, want, complete, "lastID %d", lastID)
+		broker.Unsubscribe(events)
+	}
+}
+
+// readStream returns the messages of a stream up to and including the first counts message
+func readStream(t *testing.T, url, lastEventID string) []string {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	request, err := http.NewRequest(http.MethodGet, url, nil)
+	require.NoError(t, err)
+	if lastEventID != "" {
+		request.Header.Set("Last-Event-ID", lastEventID)
+	}
+	response, err := http.DefaultClient.Do(request.WithContext(ctx))
+	require.NoError(t, err)
+	defer response.Body.Close()
+	require.Equal(t, http.StatusOK, response.StatusCode)
+
+	var messages []string
+	var message []string
+	scanner := bufio.NewScanner(response.Body)
+	for scanner.Scan() {
+		if line := scanner.Text(); line != "" {
+			message = append(message, line)
+			continue
+		}
+		messages = append(messages, strings.Join(message, "\n"))
+		if strings.Contains(messages[len(messages)-1], "event: counts") {
+			return messages
+		}
+		message = nil
+	}
+	t.Fatalf("stream ended before the counts: %v", scanner.Err())
+	return nil
+}
+
+func TestAPIStreamReplaysBacklogBeforeCounts(t *testing.T) {
+	server := newTestAPIServer(t, newTestPod("web", "a", core_v1.PodRunning, time.Now()))
+	broker := server.broker
+	for _, to := range []string{"Pending", "Running"} {
+		broker.Publish(StreamEvent{Type: streamEventPod, Pod: &PodTransition{Namespace: "web", Name: "a", To: to}})
+	}
+	broker.Publish(StreamEvent{Type: streamEventCounts})
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+	url := httpServer.URL + "/api/v1/stream?pods=true"
+
+	messages := readStream(t, url, "1")
+	require.Len(t, messages, 2)
+	require.True(t, strings.HasPrefix(messages[0], "id: 2\nevent: pod\n"), messages[0])
+	require.True(t, strings.HasPrefix(messages[1], "id: 3\nevent: counts\n"), messages[1])
+	require.Contains(t, messages[1], `"podRunningCount":1`)
+
+	// an ID from before a restart cannot be resumed
+	messages = readStream(t, url, "7")
+	require.Len(t, messages, 2)
+	require.Equal(t, "event: reset\ndata: {\"lastEventId\":7}", messages[0])
+	require.True(t, strings.HasPrefix(messages[1], "id: 3\nevent: counts\n"), messages[1])
+}
@@ -0,0 +1,357 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"golang.org/x/crypto/ssh/terminal"
+	core_v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// topSource lists the pods shown by the top subcommand
+type topSource interface {
+	Pods() ([]PodRecord, error)
+}
+
+// apiTopSource reads pods from the query API of a running controller
+type apiTopSource struct {
+	baseURL   string
+	namespace string
+	client    *http.Client
+}
+
+// informerTopSource reads pods from an informer run by the top subcommand itself
+type informerTopSource struct {
+	informer cache.SharedIndexInformer
+}
+
+// topRow holds the pod counts of one owner in one namespace. Churn is the
+// number of pods of the owner that appeared or disappeared since top started
+type topRow struct {
+	Namespace string
+	Owner     string
+	Running   int
+	Pending   int
+	Failed    int
+	Churn     int
+}
+
+// topView is the state of the top screen
+type topView struct {
+	source  string
+	rows    []topRow
+	pods    int
+	err     error
+	updated time.Time
+	// previous maps the pods seen on the last refresh to their row
+	previous map[string]string
+	churn    map[string]int
+	// filter restricts rows to namespaces or owners containing it. input holds
+	// the filter being typed while editing
+	filter  string
+	input   string
+	editing bool
+}
+
+// runTop implements the top subcommand, a continuously refreshing table of pod
+// counts per namespace and owner sorted by churn
+func runTop(args []string) error {
+	flags := flag.NewFlagSet("top", flag.ExitOnError)
+	apiURL := flags.String("api", "", "URL of the controller query API, e.g. http://localhost:8080. Without it top runs its own pod informer")
+	namespace := flags.String("namespace", "", "only show pods of this namespace")
+	interval := flags.Duration("interval", 2*time.Second, "refresh interval")
+	flags.Parse(args)
+
+	var source topSource
+	view := &topView{churn: make(map[string]int)}
+	if *apiURL != "" {
+		source = &apiTopSource{baseURL: strings.TrimSuffix(*apiURL, "/"), namespace: *namespace, client: &http.Client{Timeout: 10 * time.Second}}
+		view.source = *apiURL
+	} else {
+		client, _ := GetKubernetesClient()
+		informer := NewPodInformer(client, *namespace)
+		stopCh := make(chan struct{})
+		defer close(stopCh)
+		go informer.Run(stopCh)
+		if !cache.WaitForCacheSync(stopCh, informer.HasSynced) {
+			return fmt.Errorf("error syncing pod cache")
+		}
+		source = &informerTopSource{informer: informer}
+		view.source = "pod informer"
+	}
+
+	// keyboard input needs a raw terminal, without one top only refreshes
+	fd := int(os.Stdin.Fd())
+	keys := make(chan []byte)
+	if terminal.IsTerminal(fd) {
+		state, err := terminal.MakeRaw(fd)
+		if err != nil {
+			return err
+		}
+		defer terminal.Restore(fd, state)
+		go readKeys(os.Stdin, keys)
+	}
+
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+	view.refresh(source)
+	for {
+		view.render(os.Stdout, fd)
+		select {
+		case <-ticker.C:
+			view.refresh(source)
+		case input := <-keys:
+			if !view.handleInput(input) {
+				fmt.Fprint(os.Stdout, "\r\n")
+				return nil
+			}
+		}
+	}
+}
+
+// readKeys sends what every read returns, so the escape sequences of arrow
+// and function keys arrive in one piece
+func readKeys(r io.Reader, keys chan<- []byte) {
+	buf := make([]byte, 16)
+	for {
+		n, err := r.Read(buf)
+		if err != nil {
+			return
+		}
+		keys <- append([]byte{}, buf[:n]...)
+	}
+}
+
+// Pods lists every page of /api/v1/pods
+func (s *apiTopSource) Pods() ([]PodRecord, error) {
+	var records []PodRecord
+	query := url.Values{}
+	query.Set("limit", strconv.Itoa(maxPageLimit))
+	if s.namespace != "" {
+		query.Set("namespace", s.namespace)
+	}
+	for {
+		resp, err := s.client.Get(s.baseURL + "/api/v1/pods?" + query.Encode())
+		if err != nil {
+			return nil, err
+		}
+		var page PodPage
+		if resp.StatusCode != http.StatusOK {
+			err = fmt.Errorf("query API returned %s", resp.Status)
+		} else {
+			err = json.NewDecoder(resp.Body).Decode(&page)
+		}
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, page.Items...)
+		if page.Continue == "" {
+			return records, nil
+		}
+		query.Set("continue", page.Continue)
+	}
+}
+
+// Pods lists the pods in the informer cache
+func (s *informerTopSource) Pods() ([]PodRecord, error) {
+	var records []PodRecord
+	for _, obj := range s.informer.GetIndexer().List() {
+		if pod, ok := obj.(*core_v1.Pod); ok {
+			records = append(records, newPodRecord(pod, strings.ToLower(string(pod.Status.Phase))))
+		}
+	}
+	return records, nil
+}
+
+// refresh fetches the pods, accumulates churn and rebuilds the rows
+func (v *topView) refresh(source topSource) {
+	records, err := source.Pods()
+	v.err = err
+	if err != nil {
+		return
+	}
+	v.updated = time.Now()
+	v.pods = len(records)
+
+	rows := make(map[string]*topRow)
+	current := make(map[string]string)
+	for _, record := range records {
+		owner := record.Owner
+		if owner == "" {
+			owner = noOwner
+		}
+		rowKey := record.Namespace + "/" + owner
+		current[recordKey(record)] = rowKey
+		row, exists := rows[rowKey]
+		if !exists {
+			row = &topRow{Namespace: record.Namespace, Owner: owner}
+			rows[rowKey] = row
+		}
+		switch record.Phase {
+		case core_v1.PodRunning:
+			row.Running++
+		case core_v1.PodPending:
+			row.Pending++
+		case core_v1.PodFailed:
+			row.Failed++
+		}
+	}
+
+	// the first refresh is the baseline, churn is counted from there on
+	if v.previous != nil {
+		for key, rowKey := range current {
+			if _, existed := v.previous[key]; !existed {
+				v.churn[rowKey]++
+			}
+		}
+		for key, rowKey := range v.previous {
+			if _, exists := current[key]; !exists {
+				v.churn[rowKey]++
+			}
+		}
+	}
+	v.previous = current
+
+	for rowKey, churn := range v.churn {
+		row, exists := rows[rowKey]
+		if !exists {
+			// keep owners whose pods are all gone, their churn is still interesting
+			parts := strings.SplitN(rowKey, "/", 2)
+			row = &topRow{Namespace: parts[0], Owner: parts[1]}
+			rows[rowKey] = row
+		}
+		row.Churn = churn
+	}
+
+	v.rows = v.rows[:0]
+	for _, row := range rows {
+		v.rows = append(v.rows, *row)
+	}
+	sort.Slice(v.rows, func(i, j int) bool {
+		a, b := v.rows[i], v.rows[j]
+		if a.Churn != b.Churn {
+			return a.Churn > b.Churn
+		}
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return a.Owner < b.Owner
+	})
+}
+
+// keyEscape starts escape sequences and, on its own, is the esc key
+const keyEscape = 27
+
+// handleInput applies the keys of one read and returns false when top should
+// quit. Escape sequences, sent by arrow and function keys, are ignored
+func (v *topView) handleInput(input []byte) bool {
+	if len(input) > 1 && input[0] == keyEscape {
+		return true
+	}
+	for _, key := range input {
+		if !v.handleKey(key) {
+			return false
+		}
+	}
+	return true
+}
+
+// handleKey applies a key press and returns false when top should quit
+func (v *topView) handleKey(key byte) bool {
+	const (
+		ctrlC     = 3
+		backspace = 8
+		enter     = 13
+		escape    = keyEscape
+		del       = 127
+	)
+	if v.editing {
+		switch key {
+		case enter, '\n':
+			v.filter = v.input
+			v.editing = false
+		case escape:
+			v.editing = false
+		case backspace, del:
+			if len(v.input) > 0 {
+				v.input = v.input[:len(v.input)-1]
+			}
+		case ctrlC:
+			return false
+		default:
+			if key >= ' ' && key < del {
+				v.input += string(key)
+			}
+		}
+		return true
+	}
+	switch key {
+	case 'q', ctrlC:
+		return false
+	case '/':
+		v.editing = true
+		v.input = v.filter
+	case escape:
+		v.filter = ""
+	}
+	return true
+}
+
+func (v *topView) matches(row topRow) bool {
+	if v.filter == "" {
+		return true
+	}
+	filter := strings.ToLower(v.filter)
+	return strings.Contains(strings.ToLower(row.Namespace), filter) || strings.Contains(strings.ToLower(row.Owner), filter)
+}
+
+// render draws the screen. Lines end in \r\n as the terminal is in raw mode
+func (v *topView) render(w io.Writer, fd int) {
+	height := 0
+	if _, h, err := terminal.GetSize(fd); err == nil {
+		height = h
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "k8s-pod-monitor top - %s - %d pods - %s\n", v.source, v.pods, v.updated.Format("15:04:05"))
+	if v.editing {
+		fmt.Fprintf(&buf, "filter: %s_\n", v.input)
+	} else {
+		fmt.Fprintf(&buf, "filter: %s   (/ filter, esc clear, q quit)\n", v.filter)
+	}
+	if v.err != nil {
+		fmt.Fprintf(&buf, "error: %v\n", v.err)
+	}
+	buf.WriteString("\n")
+
+	tw := tabwriter.NewWriter(&buf, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAMESPACE\tOWNER\tRUNNING\tPENDING\tFAILED\tCHURN")
+	lines := strings.Count(buf.String(), "\n") + 1
+	for _, row := range v.rows {
+		if !v.matches(row) {
+			continue
+		}
+		if height > 0 && lines >= height-1 {
+			break
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%d\t%d\n", row.Namespace, row.Owner, row.Running, row.Pending, row.Failed, row.Churn)
+		lines++
+	}
+	tw.Flush()
+
+	// move home and clear the screen before drawing
+	io.WriteString(w, "\x1b[H\x1b[2J"+strings.Replace(buf.String(), "\n", "\r\n", -1))
+}
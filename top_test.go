@@ -0,0 +1,96 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	core_v1 "k8s.io/api/core/v1"
+)
+
+// staticTopSource returns the pods it is set to
+type staticTopSource struct {
+	records []PodRecord
+}
+
+func (s *staticTopSource) Pods() ([]PodRecord, error) {
+	return s.records, nil
+}
+
+func TestTopViewCountsChurn(t *testing.T) {
+	record := func(namespace, name, owner string, phase core_v1.PodPhase) PodRecord {
+		return PodRecord{Namespace: namespace, Name: name, Owner: owner, Phase: phase}
+	}
+	source := &staticTopSource{records: []PodRecord{
+		record("web", "frontend-a", "ReplicaSet/frontend", core_v1.PodRunning),
+		record("web", "frontend-b", "ReplicaSet/frontend", core_v1.PodPending),
+		record("batch", "report-a", "Job/report", core_v1.PodFailed),
+		record("web", "debug", "", core_v1.PodRunning),
+	}}
+	view := &topView{churn: make(map[string]int)}
+
+	// the first refresh is the baseline
+	view.refresh(source)
+	require.Len(t, view.rows, 3)
+	for _, row := range view.rows {
+		require.Zero(t, row.Churn)
+	}
+	require.Equal(t, topRow{Namespace: "web", Owner: "ReplicaSet/frontend", Running: 1, Pending: 1}, view.rows[2])
+
+	// a replaced frontend pod and the finished job cleaned up
+	source.records = []PodRecord{
+		record("web", "frontend-a", "ReplicaSet/frontend", core_v1.PodRunning),
+		record("web", "frontend-c", "ReplicaSet/frontend", core_v1.PodRunning),
+		record("web", "debug", "", core_v1.PodRunning),
+	}
+	view.refresh(source)
+	require.Equal(t, 3, view.pods)
+	require.Equal(t, []topRow{
+		{Namespace: "web", Owner: "ReplicaSet/frontend", Running: 2, Churn: 2},
+		// owners whose pods are all gone are kept for their churn
+		{Namespace: "batch", Owner: "Job/report", Churn: 1},
+		{Namespace: "web", Owner: noOwner, Running: 1},
+	}, view.rows)
+}
+
+func TestTopViewKeys(t *testing.T) {
+	view := &topView{}
+	rows := []topRow{{Namespace: "web", Owner: "ReplicaSet/frontend"}, {Namespace: "batch", Owner: "Job/Report"}}
+	matching := func() []string {
+		var owners []string
+		for _, row := range rows {
+			if view.matches(row) {
+				owners = append(owners, row.Owner)
+			}
+		}
+		return owners
+	}
+
+	// type a filter, correct it and apply it
+	require.True(t, view.handleInput([]byte("/jobx")))
+	require.True(t, view.editing)
+	require.True(t, view.handleInput([]byte{127}))
+	require.Equal(t, "job", view.input)
+	require.Len(t, matching(), 2)
+	require.True(t, view.handleInput([]byte("\r")))
+	require.False(t, view.editing)
+	require.Equal(t, []string{"Job/Report"}, matching())
+
+	// arrow keys send escape sequences, which neither cancel editing nor clear the filter
+	require.True(t, view.handleInput([]byte("/")))
+	require.True(t, view.handleInput([]byte("\x1b[A")))
+	require.True(t, view.editing)
+	require.Equal(t, "job", view.input)
+	// esc on its own cancels editing and keeps the filter
+	require.True(t, view.handleInput([]byte{keyEscape}))
+	require.False(t, view.editing)
+	require.Equal(t, "job", view.filter)
+	require.True(t, view.handleInput([]byte("\x1b[D")))
+	require.Equal(t, "job", view.filter)
+	// and clears the filter when not editing
+	require.True(t, view.handleInput([]byte{keyEscape}))
+	require.Empty(t, view.filter)
+	require.Len(t, matching(), 2)
+
+	require.False(t, view.handleInput([]byte("/q\x03")))
+	require.False(t, (&topView{}).handleInput([]byte("q")))
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"sort"
+
+	"github.com/jayapriya90/k8s-pod-monitor/v1alpha1"
+	core_v1 "k8s.io/api/core/v1"
+)
+
+// zoneLabels are the node labels holding the zone, the deprecated one last
+var zoneLabels = []string{"topology.kubernetes.io/zone", "failure-domain.beta.kubernetes.io/zone"}
+
+// nodeZone returns the zone of a node, or an empty string if it has no zone label
+func nodeZone(node *core_v1.Node) string {
+	for _, label := range zoneLabels {
+		if zone, exists := node.Labels[label]; exists {
+			return zone
+		}
+	}
+	return ""
+}
+
+// topologyStatus reports how the running replicas of every owner with more
+// than one replica are spread over nodes and zones. An owner is flagged when
+// all its replicas share a node, or a zone while the cluster has several
+func topologyStatus(pods []*core_v1.Pod, nodes []interface{}) *v1alpha1.TopologyStatus {
+	zoneOf := make(map[string]string, len(nodes))
+	zones, nodeNames := make(map[string]bool), make(map[string]bool)
+	for _, obj := range nodes {
+		if node, ok := obj.(*core_v1.Node); ok {
+			zone := nodeZone(node)
+			zoneOf[node.Name] = zone
+			nodeNames[node.Name] = true
+			if zone != "" {
+				zones[zone] = true
+			}
+		}
+	}
+
+	type spread struct {
+		replicas int32
+		nodes    map[string]bool
+		zones    map[string]int32
+	}
+	owners := make(map[string]*spread)
+	for _, pod := range pods {
+		owner := podOwner(pod)
+		if owner == "" || pod.Spec.NodeName == "" {
+			continue
+		}
+		key := pod.Namespace + "/" + owner
+		entry, exists := owners[key]
+		if !exists {
+			entry = &spread{nodes: make(map[string]bool), zones: make(map[string]int32)}
+			owners[key] = entry
+		}
+		entry.replicas++
+		entry.nodes[pod.Spec.NodeName] = true
+		if zone := zoneOf[pod.Spec.NodeName]; zone != "" {
+			entry.zones[zone]++
+		}
+	}
+
+	status := &v1alpha1.TopologyStatus{Zones: int32(len(zones))}
+	for owner, entry := range owners {
+		if entry.replicas < 2 {
+			continue
+		}
+		spread := v1alpha1.OwnerSpread{
+			Owner:      owner,
+			Replicas:   entry.replicas,
+			Nodes:      int32(len(entry.nodes)),
+			SingleNode: len(entry.nodes) == 1 && len(nodeNames) > 1,
+			SingleZone: len(entry.zones) == 1 && len(zones) > 1,
+		}
+		for zone, replicas := range entry.zones {
+			spread.Zones = append(spread.Zones, v1alpha1.ZoneReplicas{Zone: zone, Replicas: replicas})
+		}
+		sort.Slice(spread.Zones, func(i, j int) bool {
+			return spread.Zones[i].Zone < spread.Zones[j].Zone
+		})
+		status.Owners = append(status.Owners, spread)
+	}
+	sort.Slice(status.Owners, func(i, j int) bool {
+		return status.Owners[i].Owner < status.Owners[j].Owner
+	})
+	return status
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jayapriya90/k8s-pod-monitor/v1alpha1"
+	"github.com/stretchr/testify/require"
+	core_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestTopologyStatus(t *testing.T) {
+	node := func(name, zone, label string) *core_v1.Node {
+		return &core_v1.Node{ObjectMeta: meta_v1.ObjectMeta{Name: name, Labels: map[string]string{label: zone}}}
+	}
+	nodes := []interface{}{
+		node("a", "zone-1", "topology.kubernetes.io/zone"),
+		node("b", "zone-1", "topology.kubernetes.io/zone"),
+		// the deprecated label is still read
+		node("c", "zone-2", "failure-domain.beta.kubernetes.io/zone"),
+	}
+	controller := true
+	pod := func(owner, name, nodeName string) *core_v1.Pod {
+		p := newTestPod("web", name, core_v1.PodRunning, time.Now())
+		p.OwnerReferences = []meta_v1.OwnerReference{{Kind: "ReplicaSet", Name: owner, Controller: &controller}}
+		p.Spec.NodeName = nodeName
+		return &p
+	}
+	pods := []*core_v1.Pod{
+		// spread over nodes and zones
+		pod("spread", "spread-1", "a"), pod("spread", "spread-2", "c"),
+		// on one node
+		pod("packed", "packed-1", "b"), pod("packed", "packed-2", "b"),
+		// on two nodes of one zone
+		pod("zonal", "zonal-1", "a"), pod("zonal", "zonal-2", "b"),
+		// a single replica cannot be spread
+		pod("single", "single-1", "a"),
+		// not scheduled yet
+		pod("pending", "pending-1", "a"), pod("pending", "pending-2", ""),
+	}
+
+	status := topologyStatus(pods, nodes)
+	require.Equal(t, int32(2), status.Zones)
+	require.Len(t, status.Owners, 3)
+	require.Equal(t, v1alpha1.OwnerSpread{
+		Owner:      "web/ReplicaSet/packed",
+		Replicas:   2,
+		Nodes:      1,
+		Zones:      []v1alpha1.ZoneReplicas{{Zone: "zone-1", Replicas: 2}},
+		SingleNode: true,
+		SingleZone: true,
+	}, status.Owners[0])
+	require.Equal(t, v1alpha1.OwnerSpread{
+		Owner:    "web/ReplicaSet/spread",
+		Replicas: 2,
+		Nodes:    2,
+		Zones:    []v1alpha1.ZoneReplicas{{Zone: "zone-1", Replicas: 1}, {Zone: "zone-2", Replicas: 1}},
+	}, status.Owners[1])
+	require.Equal(t, "web/ReplicaSet/zonal", status.Owners[2].Owner)
+	require.False(t, status.Owners[2].SingleNode)
+	require.True(t, status.Owners[2].SingleZone)
+
+	condition := replicasConcentratedCondition(status)
+	require.Equal(t, meta_v1.ConditionTrue, condition.Status)
+	require.Equal(t, "2 owners run all replicas on one node or in one zone: web/ReplicaSet/packed, web/ReplicaSet/zonal", condition.Message)
+
+	// a cluster with a single node and without zones cannot spread replicas
+	status = topologyStatus([]*core_v1.Pod{pod("packed", "packed-1", "a"), pod("packed", "packed-2", "a")}, []interface{}{&core_v1.Node{ObjectMeta: meta_v1.ObjectMeta{Name: "a"}}})
+	require.Zero(t, status.Zones)
+	require.False(t, status.Owners[0].SingleNode)
+	require.False(t, status.Owners[0].SingleZone)
+	require.Equal(t, meta_v1.ConditionFalse, replicasConcentratedCondition(status).Status)
+}
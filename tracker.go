@@ -0,0 +1,374 @@
+package main
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jayapriya90/k8s-pod-monitor/v1alpha1"
+	core_v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+)
+
+// PodTracker holds the pod bookkeeping behind PodHandler. It has no
+// dependency on the API server so the same counting logic can be replayed
+// against pod dumps by the analyze subcommand. It is safe for concurrent use
+// so the query API can read it while the worker updates it
+type PodTracker struct {
+	mu               sync.RWMutex
+	now              func() time.Time
+	startedTimestamp time.Time
+	// podsCreated keeps the namespace and labels of every created pod by UID
+	// so created counts can still be filtered after the pods themselves are
+	// gone, and a pod recreated under the same name counts again
+	podsCreated map[string]createdPod
+	podsRunning map[string]bool
+	// pods holds the last observed state of every pod that is being tracked
+	pods       map[string]*core_v1.Pod
+	lifecycles map[string]*PodLifecycle
+	// usage accounts the pod-seconds of running pods when enabled
+	usage *UsageLedger
+	// container restarts and terminations since the monitor started
+	namespaceRestarts restartAccumulator
+	ownerRestarts     restartAccumulator
+	// eventCounts holds the occurrences counted per event UID, eventReasons
+	// the occurrences of pod events since the monitor started
+	eventCounts  map[string]int32
+	eventReasons map[eventReasonKey]int32
+}
+
+// createdPod is what is kept of a created pod
+type createdPod struct {
+	namespace string
+	labels    labels.Set
+}
+
+// createdKey returns the UID of a pod, or its key for pods without one like
+// the ones of hand-written dumps
+func createdKey(key string, pod *core_v1.Pod) string {
+	if pod.UID == "" {
+		return key
+	}
+	return string(pod.UID)
+}
+
+// PodLifecycle records when a pod went through each stage of its life
+type PodLifecycle struct {
+	Created   time.Time  `json:"created"`
+	Scheduled *time.Time `json:"scheduled,omitempty"`
+	Running   *time.Time `json:"running,omitempty"`
+	Finished  *time.Time `json:"finished,omitempty"`
+	// Events are the most recent events of the pod, oldest first
+	Events []PodEvent `json:"events,omitempty"`
+}
+
+// NewPodTracker returns a tracker which ignores pending pods created before startedTs
+func NewPodTracker(startedTs time.Time) *PodTracker {
+	return &PodTracker{
+		now:               time.Now,
+		startedTimestamp:  startedTs,
+		podsCreated:       make(map[string]createdPod),
+		podsRunning:       make(map[string]bool),
+		pods:              make(map[string]*core_v1.Pod),
+		lifecycles:        make(map[string]*PodLifecycle),
+		namespaceRestarts: restartAccumulator{},
+		ownerRestarts:     restartAccumulator{},
+		eventCounts:       make(map[string]int32),
+		eventReasons:      make(map[eventReasonKey]int32),
+	}
+}
+
+// Observe records the current state of a pod. It returns false if the pod
+// was ignored because it was already pending before the monitor started
+func (t *PodTracker) Observe(key string, pod *core_v1.Pod) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if previous, tracked := t.pods[key]; tracked && previous.UID != pod.UID {
+		// the pod was deleted and recreated under the same name before its
+		// deletion was handled
+		t.forget(key)
+	}
+	t.recordLifecycle(key, pod)
+	t.recordRestarts(pod, t.pods[key])
+	if t.usage != nil {
+		if pod.Status.Phase == core_v1.PodRunning {
+			t.usage.Start(key, pod, *t.lifecycles[key].Running)
+		} else {
+			t.usage.Stop(key, t.now())
+		}
+	}
+	if pod.Status.Phase == core_v1.PodPending {
+		if pod.CreationTimestamp.Time.Before(t.startedTimestamp) {
+			return false
+		}
+		if _, exists := t.podsCreated[createdKey(key, pod)]; !exists {
+			t.podsCreated[createdKey(key, pod)] = createdPod{namespace: pod.Namespace, labels: labels.Set(pod.Labels)}
+		}
+	}
+	if pod.Status.Phase == core_v1.PodRunning {
+		if _, exists := t.podsRunning[key]; !exists {
+			t.podsRunning[key] = true
+		}
+	}
+	t.pods[key] = pod
+	return true
+}
+
+// recordLifecycle stamps the stages a pod reached since it was last observed.
+// Scheduling time comes from the PodScheduled condition, the other stages are
+// stamped when they are first observed
+func (t *PodTracker) recordLifecycle(key string, pod *core_v1.Pod) {
+	lifecycle, exists := t.lifecycles[key]
+	if !exists {
+		lifecycle = &PodLifecycle{Created: pod.CreationTimestamp.Time}
+		t.lifecycles[key] = lifecycle
+	}
+	now := t.now()
+	if lifecycle.Scheduled == nil {
+		for _, condition := range pod.Status.Conditions {
+			if condition.Type == core_v1.PodScheduled && condition.Status == core_v1.ConditionTrue {
+				scheduled := condition.LastTransitionTime.Time
+				lifecycle.Scheduled = &scheduled
+			}
+		}
+	}
+	switch pod.Status.Phase {
+	case core_v1.PodRunning:
+		if lifecycle.Running == nil {
+			lifecycle.Running = &now
+		}
+	case core_v1.PodSucceeded, core_v1.PodFailed:
+		if lifecycle.Finished == nil {
+			lifecycle.Finished = &now
+		}
+	}
+}
+
+// Forget stops tracking a deleted pod. Pods stay in the created count
+func (t *PodTracker) Forget(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.forget(key)
+}
+
+// forget stops tracking a pod. t.mu must be held
+func (t *PodTracker) forget(key string) {
+	delete(t.podsRunning, key)
+	pod, tracked := t.pods[key]
+	delete(t.pods, key)
+	delete(t.lifecycles, key)
+	if tracked {
+		t.forgetRestarts(pod)
+	}
+	if t.usage != nil {
+		t.usage.Stop(key, t.now())
+	}
+}
+
+// EnableUsage starts accounting the usage of running pods in ledger
+func (t *PodTracker) EnableUsage(ledger *UsageLedger) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.usage = ledger
+}
+
+// AccrueUsage accounts the usage of the running pods up to now
+func (t *PodTracker) AccrueUsage() {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if t.usage != nil {
+		t.usage.Accrue(t.now())
+	}
+}
+
+// Pod returns the last observed state of a tracked pod
+func (t *PodTracker) Pod(key string) (*core_v1.Pod, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	pod, exists := t.pods[key]
+	return pod, exists
+}
+
+// Pods returns the last observed state of all tracked pods
+func (t *PodTracker) Pods() []*core_v1.Pod {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	pods := make([]*core_v1.Pod, 0, len(t.pods))
+	for _, pod := range t.pods {
+		pods = append(pods, pod)
+	}
+	return pods
+}
+
+// RunningPods returns the last observed state of the running pods
+func (t *PodTracker) RunningPods() []*core_v1.Pod {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	var pods []*core_v1.Pod
+	for _, pod := range t.pods {
+		if pod.Status.Phase == core_v1.PodRunning {
+			pods = append(pods, pod)
+		}
+	}
+	return pods
+}
+
+// StuckPods returns the keys of the tracked pods that have been pending for longer than stuckAfter
+func (t *PodTracker) StuckPods(now time.Time, stuckAfter time.Duration) []string {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	var stuck []string
+	for key, pod := range t.pods {
+		if isStuck(pod, now, stuckAfter) {
+			stuck = append(stuck, key)
+		}
+	}
+	return stuck
+}
+
+// TrackedKeys returns the keys of all pods currently tracked
+func (t *PodTracker) TrackedKeys() []string {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	keys := make([]string, 0, len(t.pods))
+	for key := range t.pods {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// Lifecycle returns a copy of the lifecycle record of a tracked pod
+func (t *PodTracker) Lifecycle(key string) (PodLifecycle, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	lifecycle, exists := t.lifecycles[key]
+	if !exists {
+		return PodLifecycle{}, false
+	}
+	copied := *lifecycle
+	copied.Events = append([]PodEvent(nil), lifecycle.Events...)
+	return copied, true
+}
+
+// CreatedCount returns the number of pods created since the monitor started
+func (t *PodTracker) CreatedCount() int {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return len(t.podsCreated)
+}
+
+// CreatedCountMatching returns the number of created pods in namespace (all
+// namespaces if empty) whose labels match selector
+func (t *PodTracker) CreatedCountMatching(namespace string, selector labels.Selector) int {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	count := 0
+	for _, created := range t.podsCreated {
+		if namespace != "" && namespace != created.namespace {
+			continue
+		}
+		if selector.Matches(created.labels) {
+			count++
+		}
+	}
+	return count
+}
+
+// RunningCount returns the number of pods currently running
+func (t *PodTracker) RunningCount() int {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return len(t.podsRunning)
+}
+
+// Status builds the PodMonitor status, including the per namespace breakdown
+func (t *PodTracker) Status() v1alpha1.PodMonitorStatus {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	namespaces := make(map[string]*v1alpha1.NamespaceStatus)
+	namespaceStatus := func(namespace string) *v1alpha1.NamespaceStatus {
+		ns, exists := namespaces[namespace]
+		if !exists {
+			ns = &v1alpha1.NamespaceStatus{Namespace: namespace}
+			namespaces[namespace] = ns
+		}
+		return ns
+	}
+	namespaceFor := func(key string) *v1alpha1.NamespaceStatus {
+		namespace, _, _ := cache.SplitMetaNamespaceKey(key)
+		return namespaceStatus(namespace)
+	}
+
+	for _, created := range t.podsCreated {
+		namespaceStatus(created.namespace).PodCreatedCount++
+	}
+	for key := range t.podsRunning {
+		namespaceFor(key).PodRunningCount++
+	}
+	for key, pod := range t.pods {
+		switch pod.Status.Phase {
+		case core_v1.PodPending:
+			namespaceFor(key).PodPendingCount++
+		case core_v1.PodFailed:
+			namespaceFor(key).PodFailedCount++
+		}
+	}
+
+	status := v1alpha1.PodMonitorStatus{
+		PodCreatedCount: int32(len(t.podsCreated)),
+		PodRunningCount: int32(len(t.podsRunning)),
+		Resources:       resourceStatus(t.pods),
+		Restarts:        t.restartStatus(),
+		Scheduling:      schedulingStatus(t.pods),
+		Events:          t.eventStatus(),
+		Security:        securityStatus(t.pods),
+	}
+	for _, ns := range namespaces {
+		status.Namespaces = append(status.Namespaces, *ns)
+	}
+	sort.Slice(status.Namespaces, func(i, j int) bool {
+		return status.Namespaces[i].Namespace < status.Namespaces[j].Namespace
+	})
+	return status
+}
+
+// isStuck reports whether a pod has been pending for longer than stuckAfter
+func isStuck(pod *core_v1.Pod, now time.Time, stuckAfter time.Duration) bool {
+	return pod.Status.Phase == core_v1.PodPending && now.Sub(pod.CreationTimestamp.Time) > stuckAfter
+}
+
+// noOwner is shown in breakdowns for pods without a controller
+const noOwner = "<none>"
+
+// podOwner returns the controlling owner of a pod as `Kind/name`, or an empty
+// string for pods without a controller
+func podOwner(pod *core_v1.Pod) string {
+	for _, ref := range pod.OwnerReferences {
+		if ref.Controller != nil && *ref.Controller {
+			return ref.Kind + "/" + ref.Name
+		}
+	}
+	return ""
+}
+
+// containerStatuses returns the statuses of the init and app containers of a pod
+func containerStatuses(pod *core_v1.Pod) []core_v1.ContainerStatus {
+	statuses := make([]core_v1.ContainerStatus, 0, len(pod.Status.InitContainerStatuses)+len(pod.Status.ContainerStatuses))
+	return append(append(statuses, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+}
+
+// podContainers returns the init and app containers of a pod
+func podContainers(pod *core_v1.Pod) []core_v1.Container {
+	containers := make([]core_v1.Container, 0, len(pod.Spec.InitContainers)+len(pod.Spec.Containers))
+	return append(append(containers, pod.Spec.InitContainers...), pod.Spec.Containers...)
+}
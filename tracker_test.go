@@ -0,0 +1,101 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	core_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+func newTestPod(namespace, name string, phase core_v1.PodPhase, created time.Time) core_v1.Pod {
+	return core_v1.Pod{
+		ObjectMeta: meta_v1.ObjectMeta{Namespace: namespace, Name: name, CreationTimestamp: meta_v1.NewTime(created)},
+		Status:     core_v1.PodStatus{Phase: phase},
+	}
+}
+
+func TestPodTrackerIgnoresPodsPendingBeforeStart(t *testing.T) {
+	started := time.Now()
+	tracker := NewPodTracker(started)
+
+	old := newTestPod("default", "old", core_v1.PodPending, started.Add(-time.Minute))
+	require.False(t, tracker.Observe("default/old", &old))
+
+	pending := newTestPod("default", "new", core_v1.PodPending, started.Add(time.Minute))
+	require.True(t, tracker.Observe("default/new", &pending))
+
+	running := newTestPod("default", "new", core_v1.PodRunning, started.Add(time.Minute))
+	require.True(t, tracker.Observe("default/new", &running))
+
+	require.Equal(t, 1, tracker.CreatedCount())
+	require.Equal(t, 1, tracker.RunningCount())
+
+	tracker.Forget("default/new")
+	require.Equal(t, 1, tracker.CreatedCount())
+	require.Equal(t, 0, tracker.RunningCount())
+}
+
+func TestPodTrackerCountsPodRecreatedUnderTheSameKey(t *testing.T) {
+	started := time.Now()
+	tracker := NewPodTracker(started)
+
+	pod := newTestPod("db", "postgres-0", core_v1.PodPending, started.Add(time.Minute))
+	pod.UID, pod.Labels = "uid-1", map[string]string{"app": "postgres"}
+	require.True(t, tracker.Observe("db/postgres-0", &pod))
+	tracker.Forget("db/postgres-0")
+
+	// the StatefulSet recreates the pod under the same name
+	recreated := newTestPod("db", "postgres-0", core_v1.PodPending, started.Add(2*time.Minute))
+	recreated.UID, recreated.Labels = "uid-2", map[string]string{"app": "postgres"}
+	require.True(t, tracker.Observe("db/postgres-0", &recreated))
+	require.True(t, tracker.Observe("db/postgres-0", &recreated))
+
+	require.Equal(t, 2, tracker.CreatedCount())
+	require.Equal(t, 2, tracker.CreatedCountMatching("db", labels.SelectorFromSet(labels.Set{"app": "postgres"})))
+	require.Zero(t, tracker.CreatedCountMatching("web", labels.Everything()))
+	require.Equal(t, int32(2), tracker.Status().Namespaces[0].PodCreatedCount)
+}
+
+func TestPodTrackerForgetsPodRecreatedUnderTheSameKey(t *testing.T) {
+	start := time.Date(2019, 10, 1, 10, 0, 0, 0, time.UTC)
+	now := start
+	tracker := NewPodTracker(time.Time{})
+	tracker.now = func() time.Time { return now }
+	ledger := NewUsageLedger("team", 24*time.Hour)
+	tracker.EnableUsage(ledger)
+
+	old := newTestPod("db", "postgres-0", core_v1.PodRunning, start)
+	old.UID, old.Labels = "uid-old", map[string]string{"team": "payments"}
+	old.Status.ContainerStatuses = []core_v1.ContainerStatus{{Name: "postgres"}}
+	tracker.Observe("db/postgres-0", &old)
+
+	// the delete and the re-create were merged into one update of the key
+	now = start.Add(time.Hour)
+	recreated := newTestPod("db", "postgres-0", core_v1.PodPending, now)
+	recreated.UID, recreated.Labels = "uid-new", map[string]string{"team": "search"}
+	recreated.Status.ContainerStatuses = []core_v1.ContainerStatus{{Name: "postgres"}}
+	tracker.Observe("db/postgres-0", &recreated)
+
+	lifecycle, exists := tracker.Lifecycle("db/postgres-0")
+	require.True(t, exists)
+	require.Equal(t, now, lifecycle.Created)
+	require.Nil(t, lifecycle.Running)
+	require.Zero(t, tracker.RunningCount())
+
+	// the old pod is billed until it was replaced, the new one to its own team
+	running := recreated.DeepCopy()
+	running.Status.Phase = core_v1.PodRunning
+	tracker.Observe("db/postgres-0", running)
+	now = start.Add(2 * time.Hour)
+	tracker.AccrueUsage()
+	snapshot := ledger.Snapshot()
+	require.Equal(t, map[string]time.Time{"uid-new": now}, snapshot.Pods)
+	require.Len(t, snapshot.Records, 2)
+	require.Equal(t, "payments", snapshot.Records[0].Team)
+	require.Equal(t, 3600.0, snapshot.Records[0].PodSeconds)
+	require.Equal(t, "search", snapshot.Records[1].Team)
+	require.Equal(t, 3600.0, snapshot.Records[1].PodSeconds)
+}
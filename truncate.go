@@ -0,0 +1,103 @@
+package main
+
+import (
+	"sort"
+
+	"github.com/jayapriya90/k8s-pod-monitor/v1alpha1"
+)
+
+// statusListLimit is the number of entries the lists of the pod-monitor
+// resource are cut to, the query API serves the full lists
+const statusListLimit = 20
+
+// truncated returns how many of n entries are left out by statusListLimit
+func truncated(n int) int32 {
+	if n <= statusListLimit {
+		return 0
+	}
+	return int32(n - statusListLimit)
+}
+
+// truncateStatus returns a copy of the status with the lists that grow with
+// the cluster cut to their statusListLimit most significant entries, so the
+// pod-monitor resource stays within the object size limit of etcd
+func truncateStatus(status v1alpha1.PodMonitorStatus) v1alpha1.PodMonitorStatus {
+	out := *status.DeepCopy()
+	if resources := out.Resources; resources != nil {
+		sort.SliceStable(resources.Owners, func(i, j int) bool {
+			return resources.Owners[i].Pods > resources.Owners[j].Pods
+		})
+		if resources.TruncatedOwners = truncated(len(resources.Owners)); resources.TruncatedOwners > 0 {
+			resources.Owners = resources.Owners[:statusListLimit]
+		}
+	}
+	if nodes := out.Nodes; nodes != nil {
+		sort.SliceStable(nodes.Nodes, func(i, j int) bool {
+			a, b := nodes.Nodes[i], nodes.Nodes[j]
+			if a.Ready != b.Ready {
+				return !a.Ready
+			}
+			return a.UtilizationPercent > b.UtilizationPercent
+		})
+		if nodes.TruncatedNodes = truncated(len(nodes.Nodes)); nodes.TruncatedNodes > 0 {
+			nodes.Nodes = nodes.Nodes[:statusListLimit]
+		}
+	}
+	if topology := out.Topology; topology != nil {
+		sort.SliceStable(topology.Owners, func(i, j int) bool {
+			a, b := topology.Owners[i], topology.Owners[j]
+			if a.SingleNode != b.SingleNode {
+				return a.SingleNode
+			}
+			if a.SingleZone != b.SingleZone {
+				return a.SingleZone
+			}
+			return a.Replicas > b.Replicas
+		})
+		if topology.TruncatedOwners = truncated(len(topology.Owners)); topology.TruncatedOwners > 0 {
+			topology.Owners = topology.Owners[:statusListLimit]
+		}
+	}
+	if jobs := out.Jobs; jobs != nil {
+		for _, outcomes := range []*[]v1alpha1.JobOutcome{&jobs.Jobs, &jobs.CronJobs} {
+			list := *outcomes
+			sort.SliceStable(list, func(i, j int) bool {
+				return list[i].Failed > list[j].Failed
+			})
+		}
+		if jobs.TruncatedJobs = truncated(len(jobs.Jobs)); jobs.TruncatedJobs > 0 {
+			jobs.Jobs = jobs.Jobs[:statusListLimit]
+		}
+		if jobs.TruncatedCronJobs = truncated(len(jobs.CronJobs)); jobs.TruncatedCronJobs > 0 {
+			jobs.CronJobs = jobs.CronJobs[:statusListLimit]
+		}
+	}
+	if policy := out.Policy; policy != nil {
+		sort.SliceStable(policy.Owners, func(i, j int) bool {
+			return policy.Owners[i].Pods > policy.Owners[j].Pods
+		})
+		if policy.TruncatedOwners = truncated(len(policy.Owners)); policy.TruncatedOwners > 0 {
+			policy.Owners = policy.Owners[:statusListLimit]
+		}
+	}
+	if services := out.Services; services != nil {
+		if services.TruncatedNotReady = truncated(len(services.NotReady)); services.TruncatedNotReady > 0 {
+			services.NotReady = services.NotReady[:statusListLimit]
+		}
+		if services.TruncatedUnmatched = truncated(len(services.Unmatched)); services.TruncatedUnmatched > 0 {
+			services.Unmatched = services.Unmatched[:statusListLimit]
+		}
+	}
+	for i := range out.Unowned {
+		unowned := &out.Unowned[i]
+		if unowned.TruncatedPods = truncated(len(unowned.Pods)); unowned.TruncatedPods > 0 {
+			unowned.Pods = unowned.Pods[:statusListLimit]
+		}
+	}
+	if images := out.Images; images != nil {
+		if images.TruncatedNonCompliant = truncated(len(images.NonCompliant)); images.TruncatedNonCompliant > 0 {
+			images.NonCompliant = images.NonCompliant[:statusListLimit]
+		}
+	}
+	return out
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/jayapriya90/k8s-pod-monitor/v1alpha1"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTruncateStatus(t *testing.T) {
+	status := v1alpha1.PodMonitorStatus{
+		Nodes:   &v1alpha1.NodesStatus{},
+		Unowned: []v1alpha1.NamespaceUnownedPods{{Namespace: "web"}},
+		Images:  &v1alpha1.ImagesStatus{},
+	}
+	for i := 0; i < statusListLimit+5; i++ {
+		name := fmt.Sprintf("node-%02d", i)
+		status.Nodes.Nodes = append(status.Nodes.Nodes, v1alpha1.NodeStatus{Name: name, Ready: i != 24, UtilizationPercent: int32(i)})
+		status.Unowned[0].Pods = append(status.Unowned[0].Pods, v1alpha1.UnownedPod{Name: name})
+	}
+	status.Images.NonCompliant = []v1alpha1.NonCompliantImage{{Pod: "a"}}
+
+	truncated := truncateStatus(status)
+	require.Len(t, truncated.Nodes.Nodes, statusListLimit)
+	require.Equal(t, int32(5), truncated.Nodes.TruncatedNodes)
+	// not ready nodes come first, then the most utilized ones
+	require.Equal(t, "node-24", truncated.Nodes.Nodes[0].Name)
+	require.Equal(t, "node-23", truncated.Nodes.Nodes[1].Name)
+	require.Len(t, truncated.Unowned[0].Pods, statusListLimit)
+	require.Equal(t, int32(5), truncated.Unowned[0].TruncatedPods)
+	require.Len(t, truncated.Images.NonCompliant, 1)
+	require.Zero(t, truncated.Images.TruncatedNonCompliant)
+
+	// the full status is left untouched
+	require.Len(t, status.Nodes.Nodes, statusListLimit+5)
+	require.Equal(t, "node-00", status.Nodes.Nodes[0].Name)
+}
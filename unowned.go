@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/jayapriya90/k8s-pod-monitor/v1alpha1"
+	core_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	// unownedNaked marks pods without ownerReferences, nothing recreates them
+	unownedNaked = "Naked"
+	// unownedOrphaned marks pods whose controller no longer exists
+	unownedOrphaned = "Orphaned"
+	// mirrorPodAnnotation marks the API mirror of a static pod, which the kubelet manages
+	mirrorPodAnnotation = "kubernetes.io/config.mirror"
+)
+
+// unownedReason returns whether a pod is naked or orphaned. owners are the
+// caches of the controller kinds, pods owned by other kinds are never orphaned
+func unownedReason(pod *core_v1.Pod, owners map[string]cache.Store) (string, string) {
+	if len(pod.OwnerReferences) == 0 {
+		return unownedNaked, ""
+	}
+	ref := meta_v1.GetControllerOf(pod)
+	if ref == nil {
+		return "", ""
+	}
+	store, known := owners[ref.Kind]
+	if !known {
+		return "", ""
+	}
+	obj, exists, err := store.GetByKey(pod.Namespace + "/" + ref.Name)
+	if err != nil {
+		return "", ""
+	}
+	if exists {
+		// an owner recreated under the same name does not adopt the pod
+		if owner, ok := obj.(meta_v1.Object); !ok || owner.GetUID() == ref.UID {
+			return "", ""
+		}
+	}
+	return unownedOrphaned, ref.Kind + "/" + ref.Name
+}
+
+// ownerCaches returns the caches of the controller kinds
+func ownerCaches(owners map[string]cache.Store) []cache.Store {
+	stores := make([]cache.Store, 0, len(owners))
+	for _, store := range owners {
+		stores = append(stores, store)
+	}
+	return stores
+}
+
+// unownedStatus reports the running and pending pods that are naked or
+// orphaned per namespace with their age. Static pods are skipped
+func unownedStatus(pods []*core_v1.Pod, owners map[string]cache.Store) []v1alpha1.NamespaceUnownedPods {
+	namespaces := make(map[string]*v1alpha1.NamespaceUnownedPods)
+	for _, pod := range pods {
+		if pod.Status.Phase != core_v1.PodRunning && pod.Status.Phase != core_v1.PodPending {
+			continue
+		}
+		if _, mirror := pod.Annotations[mirrorPodAnnotation]; mirror {
+			continue
+		}
+		reason, owner := unownedReason(pod, owners)
+		if reason == "" {
+			continue
+		}
+		ns, exists := namespaces[pod.Namespace]
+		if !exists {
+			ns = &v1alpha1.NamespaceUnownedPods{Namespace: pod.Namespace}
+			namespaces[pod.Namespace] = ns
+		}
+		if reason == unownedNaked {
+			ns.Naked++
+		} else {
+			ns.Orphaned++
+		}
+		ns.Pods = append(ns.Pods, v1alpha1.UnownedPod{
+			Name:    pod.Name,
+			Reason:  reason,
+			Owner:   owner,
+			Created: pod.CreationTimestamp,
+		})
+	}
+
+	status := make([]v1alpha1.NamespaceUnownedPods, 0, len(namespaces))
+	for _, ns := range namespaces {
+		sort.Slice(ns.Pods, func(i, j int) bool {
+			return ns.Pods[i].Name < ns.Pods[j].Name
+		})
+		status = append(status, *ns)
+	}
+	sort.Slice(status, func(i, j int) bool {
+		return status[i].Namespace < status[j].Namespace
+	})
+	return status
+}
+
+// unownedPodsCondition reports the naked or orphaned pods the spec alerts on
+func unownedPodsCondition(namespaces []v1alpha1.NamespaceUnownedPods, spec *v1alpha1.UnownedPodsSpec) v1alpha1.PodMonitorCondition {
+	excluded := make(map[string]bool, len(spec.ExcludedNamespaces))
+	for _, namespace := range spec.ExcludedNamespaces {
+		excluded[namespace] = true
+	}
+	var keys []string
+	for _, ns := range namespaces {
+		if excluded[ns.Namespace] {
+			continue
+		}
+		for _, pod := range ns.Pods {
+			if (pod.Reason == unownedNaked && spec.AlertNaked) || (pod.Reason == unownedOrphaned && spec.AlertOrphaned) {
+				keys = append(keys, ns.Namespace+"/"+pod.Name)
+			}
+		}
+	}
+	if len(keys) == 0 {
+		return v1alpha1.PodMonitorCondition{Type: conditionUnownedPods, Status: meta_v1.ConditionFalse, Reason: "PodsControlled"}
+	}
+	return v1alpha1.PodMonitorCondition{
+		Type:    conditionUnownedPods,
+		Status:  meta_v1.ConditionTrue,
+		Reason:  "PodsWithoutController",
+		Message: fmt.Sprintf("%d pods have no controller to recreate them: %s", len(keys), summarizeKeys(keys, 5)),
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jayapriya90/k8s-pod-monitor/v1alpha1"
+	"github.com/stretchr/testify/require"
+	apps_v1 "k8s.io/api/apps/v1"
+	core_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestUnownedStatus(t *testing.T) {
+	now := time.Now()
+	replicaSets := cache.NewStore(cache.MetaNamespaceKeyFunc)
+	require.NoError(t, replicaSets.Add(&apps_v1.ReplicaSet{ObjectMeta: meta_v1.ObjectMeta{Namespace: "web", Name: "web-1", UID: "rs-1"}}))
+	owners := map[string]cache.Store{"ReplicaSet": replicaSets}
+	controller := true
+	ownedBy := func(pod *core_v1.Pod, name string, uid types.UID) {
+		pod.OwnerReferences = []meta_v1.OwnerReference{{Kind: "ReplicaSet", Name: name, UID: uid, Controller: &controller}}
+	}
+
+	naked := newTestPod("web", "a", core_v1.PodRunning, now.Add(-time.Hour))
+	owned := newTestPod("web", "b", core_v1.PodRunning, now)
+	ownedBy(&owned, "web-1", "rs-1")
+	orphaned := newTestPod("web", "c", core_v1.PodRunning, now)
+	ownedBy(&orphaned, "web-0", "rs-0")
+	recreated := newTestPod("web", "d", core_v1.PodPending, now)
+	ownedBy(&recreated, "web-1", "rs-old")
+	static := newTestPod("kube-system", "e", core_v1.PodRunning, now)
+	static.Annotations = map[string]string{mirrorPodAnnotation: "hash"}
+	finished := newTestPod("web", "f", core_v1.PodSucceeded, now)
+
+	status := unownedStatus([]*core_v1.Pod{&naked, &owned, &orphaned, &recreated, &static, &finished}, owners)
+	require.Len(t, status, 1)
+	require.Equal(t, int32(1), status[0].Naked)
+	require.Equal(t, int32(2), status[0].Orphaned)
+	require.Equal(t, v1alpha1.UnownedPod{Name: "a", Reason: unownedNaked, Created: naked.CreationTimestamp}, status[0].Pods[0])
+	require.Equal(t, "ReplicaSet/web-0", status[0].Pods[1].Owner)
+
+	condition := unownedPodsCondition(status, &v1alpha1.UnownedPodsSpec{AlertNaked: true})
+	require.Equal(t, meta_v1.ConditionTrue, condition.Status)
+	require.Contains(t, condition.Message, "1 pods")
+	condition = unownedPodsCondition(status, &v1alpha1.UnownedPodsSpec{AlertOrphaned: true, ExcludedNamespaces: []string{"web"}})
+	require.Equal(t, meta_v1.ConditionFalse, condition.Status)
+}
@@ -0,0 +1,300 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"sync"
+	"time"
+
+	core_v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+)
+
+// usageDataKey is the ConfigMap key holding the gzipped usage snapshot
+const usageDataKey = "usage.json.gz"
+
+// usageDataLimit is the largest gzipped snapshot saved, leaving room for the
+// ConfigMap metadata within the 1MiB object size limit of etcd
+const usageDataLimit = 1<<20 - 64<<10
+
+// UsageRecord is the usage accumulated by the running pods of a namespace and
+// team during one hour. Resource usage is based on the pod requests
+type UsageRecord struct {
+	Hour              time.Time `json:"hour"`
+	Namespace         string    `json:"namespace"`
+	Team              string    `json:"team,omitempty"`
+	PodSeconds        float64   `json:"podSeconds"`
+	CPUCoreSeconds    float64   `json:"cpuCoreSeconds"`
+	MemoryByteSeconds float64   `json:"memoryByteSeconds"`
+}
+
+// UsageSnapshot is the persisted state of a UsageLedger. Pods maps the UID of
+// every running pod to the time its usage has been accounted until, so usage
+// continues where it left off after a restart
+type UsageSnapshot struct {
+	Records []UsageRecord        `json:"records"`
+	Pods    map[string]time.Time `json:"pods,omitempty"`
+}
+
+type usageKey struct {
+	hour      int64
+	namespace string
+	team      string
+}
+
+// usagePod is a running pod whose usage is being accounted
+type usagePod struct {
+	uid       string
+	namespace string
+	team      string
+	cpu       float64
+	memory    float64
+	since     time.Time
+}
+
+// UsageLedger accumulates pod-seconds and requested resource-seconds of
+// running pods in hourly buckets per namespace and team
+type UsageLedger struct {
+	mu        sync.Mutex
+	teamLabel string
+	retention time.Duration
+	buckets   map[usageKey]*UsageRecord
+	running   map[string]*usagePod
+	// resumed holds the accounted-until times of a loaded snapshot by pod UID
+	resumed map[string]time.Time
+}
+
+// NewUsageLedger returns a ledger grouping usage by the teamLabel pod label
+// and keeping buckets for retention
+func NewUsageLedger(teamLabel string, retention time.Duration) *UsageLedger {
+	return &UsageLedger{
+		teamLabel: teamLabel,
+		retention: retention,
+		buckets:   make(map[usageKey]*UsageRecord),
+		running:   make(map[string]*usagePod),
+		resumed:   make(map[string]time.Time),
+	}
+}
+
+// Start begins accounting a running pod from since, or from where a previous
+// run of the controller left off
+func (l *UsageLedger) Start(key string, pod *core_v1.Pod, since time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, exists := l.running[key]; exists {
+		return
+	}
+	if resumed, exists := l.resumed[string(pod.UID)]; exists {
+		since = resumed
+		delete(l.resumed, string(pod.UID))
+	}
+	requests, _ := podResources(pod)
+	l.running[key] = &usagePod{
+		uid:       string(pod.UID),
+		namespace: pod.Namespace,
+		team:      pod.Labels[l.teamLabel],
+		cpu:       quantityValue(requests[core_v1.ResourceCPU]),
+		memory:    quantityValue(requests[core_v1.ResourceMemory]),
+		since:     since,
+	}
+}
+
+// Stop accounts a pod up to until and stops accounting it
+func (l *UsageLedger) Stop(key string, until time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if pod, exists := l.running[key]; exists {
+		l.accrue(pod, until)
+		delete(l.running, key)
+	}
+}
+
+// Accrue accounts every running pod up to now and drops expired buckets
+func (l *UsageLedger) Accrue(now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, pod := range l.running {
+		l.accrue(pod, now)
+	}
+	cutoff := now.Add(-l.retention)
+	for key := range l.buckets {
+		if key.hour < cutoff.Unix() {
+			delete(l.buckets, key)
+		}
+	}
+	// pods of a loaded snapshot that were deleted while the controller was down never come back
+	for uid, since := range l.resumed {
+		if since.Before(cutoff) {
+			delete(l.resumed, uid)
+		}
+	}
+}
+
+// accrue adds the usage of a pod from its since time to until, split at hour boundaries
+func (l *UsageLedger) accrue(pod *usagePod, until time.Time) {
+	for pod.since.Before(until) {
+		hour := pod.since.Truncate(time.Hour)
+		end := hour.Add(time.Hour)
+		if end.After(until) {
+			end = until
+		}
+		seconds := end.Sub(pod.since).Seconds()
+		key := usageKey{hour: hour.Unix(), namespace: pod.namespace, team: pod.team}
+		record, exists := l.buckets[key]
+		if !exists {
+			record = &UsageRecord{Hour: hour.UTC(), Namespace: pod.namespace, Team: pod.team}
+			l.buckets[key] = record
+		}
+		record.PodSeconds += seconds
+		record.CPUCoreSeconds += seconds * pod.cpu
+		record.MemoryByteSeconds += seconds * pod.memory
+		pod.since = end
+	}
+}
+
+// Snapshot returns the buckets sorted by hour, namespace and team, and the
+// accounted-until time of the running pods
+func (l *UsageLedger) Snapshot() UsageSnapshot {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	snapshot := UsageSnapshot{Records: make([]UsageRecord, 0, len(l.buckets)), Pods: make(map[string]time.Time)}
+	for _, record := range l.buckets {
+		snapshot.Records = append(snapshot.Records, *record)
+	}
+	sortUsageRecords(snapshot.Records)
+	for _, pod := range l.running {
+		snapshot.Pods[pod.uid] = pod.since
+	}
+	// pods of the loaded snapshot that have not been seen again yet
+	for uid, since := range l.resumed {
+		snapshot.Pods[uid] = since
+	}
+	return snapshot
+}
+
+// Load restores the state of a previous run. It has to be called before any pod is started
+func (l *UsageLedger) Load(snapshot UsageSnapshot) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for i := range snapshot.Records {
+		record := snapshot.Records[i]
+		l.buckets[usageKey{hour: record.Hour.Unix(), namespace: record.Namespace, team: record.Team}] = &record
+	}
+	for uid, since := range snapshot.Pods {
+		l.resumed[uid] = since
+	}
+}
+
+func sortUsageRecords(records []UsageRecord) {
+	sort.Slice(records, func(i, j int) bool {
+		a, b := records[i], records[j]
+		if !a.Hour.Equal(b.Hour) {
+			return a.Hour.Before(b.Hour)
+		}
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return a.Team < b.Team
+	})
+}
+
+// usagePersister loads and saves usage snapshots
+type usagePersister interface {
+	Load() (UsageSnapshot, error)
+	Save(snapshot UsageSnapshot) error
+}
+
+// UsageStore persists usage snapshots in a ConfigMap. Snapshots are gzipped
+// to stay well below the ConfigMap size limit
+type UsageStore struct {
+	client    kubernetes.Interface
+	namespace string
+	name      string
+}
+
+// NewUsageStore returns a store for the ConfigMap name in namespace
+func NewUsageStore(client kubernetes.Interface, namespace, name string) *UsageStore {
+	return &UsageStore{client: client, namespace: namespace, name: name}
+}
+
+// Load reads the persisted snapshot. A missing ConfigMap is an empty snapshot
+func (s *UsageStore) Load() (UsageSnapshot, error) {
+	var snapshot UsageSnapshot
+	configMap, err := s.client.CoreV1().ConfigMaps(s.namespace).Get(s.name, meta_v1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return snapshot, nil
+	}
+	if err != nil {
+		return snapshot, err
+	}
+	data, exists := configMap.BinaryData[usageDataKey]
+	if !exists {
+		return snapshot, nil
+	}
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return snapshot, err
+	}
+	defer reader.Close()
+	raw, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return snapshot, err
+	}
+	err = json.Unmarshal(raw, &snapshot)
+	return snapshot, err
+}
+
+// Save writes the snapshot, creating the ConfigMap if needed. Snapshots that
+// do not fit in a ConfigMap are not saved, and the update is retried on
+// conflicts with other writers
+func (s *UsageStore) Save(snapshot UsageSnapshot) error {
+	raw, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write(raw); err != nil {
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+	if buf.Len() > usageDataLimit {
+		return fmt.Errorf("usage snapshot of %d records is %d bytes gzipped, more than the %d bytes a ConfigMap holds. Lower -usage-retention", len(snapshot.Records), buf.Len(), usageDataLimit)
+	}
+
+	configMaps := s.client.CoreV1().ConfigMaps(s.namespace)
+	return retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		configMap, err := configMaps.Get(s.name, meta_v1.GetOptions{})
+		if errors.IsNotFound(err) {
+			_, err = configMaps.Create(&core_v1.ConfigMap{
+				ObjectMeta: meta_v1.ObjectMeta{Name: s.name, Namespace: s.namespace},
+				BinaryData: map[string][]byte{usageDataKey: buf.Bytes()},
+			})
+			if errors.IsAlreadyExists(err) {
+				// created by another writer since the get, update it on the next attempt
+				return errors.NewConflict(core_v1.Resource("configmaps"), s.name, err)
+			}
+			return err
+		}
+		if err != nil {
+			return err
+		}
+		configMap.BinaryData = map[string][]byte{usageDataKey: buf.Bytes()}
+		_, err = configMaps.Update(configMap)
+		return err
+	})
+}
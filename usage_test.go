@@ -0,0 +1,82 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	core_v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestUsageLedgerSplitsHoursAndResumes(t *testing.T) {
+	hour := time.Date(2019, 10, 1, 10, 0, 0, 0, time.UTC)
+	pod := newTestPod("web", "a", core_v1.PodRunning, hour)
+	pod.UID = "uid-a"
+	pod.Labels = map[string]string{"team": "payments"}
+	pod.Spec.Containers = []core_v1.Container{{Resources: core_v1.ResourceRequirements{Requests: core_v1.ResourceList{
+		core_v1.ResourceCPU: resource.MustParse("500m"),
+	}}}}
+
+	ledger := NewUsageLedger("team", 24*time.Hour)
+	ledger.Start("web/a", &pod, hour.Add(30*time.Minute))
+	ledger.Accrue(hour.Add(90 * time.Minute))
+
+	snapshot := ledger.Snapshot()
+	require.Len(t, snapshot.Records, 2)
+	require.Equal(t, "payments", snapshot.Records[0].Team)
+	require.Equal(t, 1800.0, snapshot.Records[0].PodSeconds)
+	require.Equal(t, 900.0, snapshot.Records[0].CPUCoreSeconds)
+	require.Equal(t, 1800.0, snapshot.Records[1].PodSeconds)
+	require.Equal(t, hour.Add(90*time.Minute), snapshot.Pods["uid-a"])
+
+	// a restarted controller continues where the snapshot left off
+	restarted := NewUsageLedger("team", 24*time.Hour)
+	restarted.Load(snapshot)
+	restarted.Start("web/a", &pod, hour.Add(3*time.Hour))
+	restarted.Stop("web/a", hour.Add(2*time.Hour))
+
+	report := buildUsageReport(restarted.Snapshot().Records, hour, hour.Add(3*time.Hour), "team")
+	require.Len(t, report.Lines, 1)
+	require.Equal(t, 5400.0, report.Lines[0].PodSeconds)
+}
+
+func TestUsageReportIncludesOverlappingHours(t *testing.T) {
+	hour := time.Date(2019, 10, 1, 10, 0, 0, 0, time.UTC)
+	var records []UsageRecord
+	for i := 0; i < 4; i++ {
+		records = append(records, UsageRecord{Hour: hour.Add(time.Duration(i) * time.Hour), Namespace: "web", PodSeconds: 3600})
+	}
+
+	// 10:30 to 12:00 overlaps the 10:00 and 11:00 hours
+	report := buildUsageReport(records, hour.Add(30*time.Minute), hour.Add(2*time.Hour), "namespace")
+	require.Len(t, report.Lines, 1)
+	require.Equal(t, float64(2*3600), report.Lines[0].PodSeconds)
+}
+
+// failingUsageStore fails to load and counts the saves
+type failingUsageStore struct {
+	saves int
+}
+
+func (s *failingUsageStore) Load() (UsageSnapshot, error) {
+	return UsageSnapshot{}, errors.New("the server was unable to return a response in the time allotted")
+}
+
+func (s *failingUsageStore) Save(snapshot UsageSnapshot) error {
+	s.saves++
+	return nil
+}
+
+func TestPodHandlerDoesNotSaveUsageItFailedToLoad(t *testing.T) {
+	store := &failingUsageStore{}
+	handler := &PodHandler{tracker: NewPodTracker(time.Time{}), options: HandlerOptions{Usage: NewUsageLedger("team", time.Hour), UsageStore: store}}
+	handler.loadUsage()
+	handler.tracker.EnableUsage(handler.options.Usage)
+
+	pod := newTestPod("web", "a", core_v1.PodRunning, time.Now())
+	handler.tracker.Observe("web/a", &pod)
+	handler.PersistUsage()
+	require.Zero(t, store.saves)
+}
@@ -1,6 +1,10 @@
 package v1alpha1
 
-import meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+import (
+	core_v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
 
 // PodMonitor ...
 type PodMonitor struct {
@@ -11,12 +15,460 @@ type PodMonitor struct {
 }
 
 // PodMonitorSpec ...
-type PodMonitorSpec struct{}
+type PodMonitorSpec struct {
+	// Policy holds the hygiene rules pods are checked against
+	Policy *PolicySpec `json:"policy,omitempty"`
+	// UnownedPods opts into alerting on naked and orphaned pods
+	UnownedPods *UnownedPodsSpec `json:"unownedPods,omitempty"`
+	// Images restricts the images running pods may use
+	Images *ImagePolicySpec `json:"images,omitempty"`
+}
+
+// PolicySpec are pod hygiene rules. Violations are reported, never enforced
+type PolicySpec struct {
+	// RequiredLabels every pod has to carry
+	RequiredLabels []string `json:"requiredLabels,omitempty"`
+	// RequireMemoryLimits requires every container to set a memory limit
+	RequireMemoryLimits bool `json:"requireMemoryLimits,omitempty"`
+	// RequireCPULimits requires every container to set a CPU limit
+	RequireCPULimits bool `json:"requireCPULimits,omitempty"`
+	// RequireReadinessProbe requires every app container to define a readiness probe
+	RequireReadinessProbe bool `json:"requireReadinessProbe,omitempty"`
+	// DisallowLatestTag rejects images tagged latest or without a tag or digest
+	DisallowLatestTag bool `json:"disallowLatestTag,omitempty"`
+	// ExcludedNamespaces are not checked
+	ExcludedNamespaces []string `json:"excludedNamespaces,omitempty"`
+}
+
+// UnownedPodsSpec selects the pods without controller the UnownedPods condition is raised for
+type UnownedPodsSpec struct {
+	// AlertNaked alerts on pods without ownerReferences
+	AlertNaked bool `json:"alertNaked,omitempty"`
+	// AlertOrphaned alerts on pods whose controller no longer exists
+	AlertOrphaned bool `json:"alertOrphaned,omitempty"`
+	// ExcludedNamespaces never alert
+	ExcludedNamespaces []string `json:"excludedNamespaces,omitempty"`
+}
+
+// ImagePolicySpec lists the images and registries running pods may not or may use
+type ImagePolicySpec struct {
+	// DeniedImages are glob patterns matched against the image as written and
+	// its fully qualified form, e.g. docker.io/library/nginx:1.14*
+	DeniedImages []string `json:"deniedImages,omitempty"`
+	// AllowedRegistries when set are the only registries images may come from
+	AllowedRegistries []string `json:"allowedRegistries,omitempty"`
+	// ExcludedNamespaces are not checked
+	ExcludedNamespaces []string `json:"excludedNamespaces,omitempty"`
+}
 
 // PodMonitorStatus ...
 type PodMonitorStatus struct {
 	PodCreatedCount int32 `json:"podCreatedCount,omitempty"`
 	PodRunningCount int32 `json:"podRunningCount,omitempty"`
+	// Namespaces breaks the counts down per namespace
+	Namespaces []NamespaceStatus `json:"namespaces,omitempty"`
+	// Resources sums the requests and limits of the running pods
+	Resources *ResourceStatus `json:"resources,omitempty"`
+	// Nodes reports running pods per node against the node pod capacity
+	Nodes *NodesStatus `json:"nodes,omitempty"`
+	// Topology reports how the running replicas of each owner are spread over nodes and zones
+	Topology *TopologyStatus `json:"topology,omitempty"`
+	// Restarts sums container restarts and terminations since the monitor started
+	Restarts *RestartStatus `json:"restarts,omitempty"`
+	// Scheduling counts the pending pods the scheduler could not place by reason
+	Scheduling *SchedulingStatus `json:"scheduling,omitempty"`
+	// Events counts the occurrences of events of tracked pods by type and reason
+	Events *EventStatus `json:"events,omitempty"`
+	// Jobs reports the outcome of the Jobs that finished within a rolling window
+	Jobs *JobsStatus `json:"jobs,omitempty"`
+	// Rollouts are the Deployment and StatefulSet rollouts in progress or recently completed
+	Rollouts []RolloutStatus `json:"rollouts,omitempty"`
+	// Shortfalls are the workloads with fewer ready pods than desired replicas
+	Shortfalls []ReplicaShortfall `json:"shortfalls,omitempty"`
+	// Security evaluates the running and pending pods against the Pod Security Standards per namespace
+	Security []NamespaceSecurity `json:"security,omitempty"`
+	// Policy reports the pods violating the policy of the spec
+	Policy *PolicyStatus `json:"policy,omitempty"`
+	// NetworkCoverage counts the running pods not selected by any NetworkPolicy per namespace
+	NetworkCoverage []NamespaceNetworkCoverage `json:"networkCoverage,omitempty"`
+	// MissingReferences are the objects waiting pods reference that do not exist, per namespace
+	MissingReferences []NamespaceMissingReferences `json:"missingReferences,omitempty"`
+	// Services reports running pods not ready in their Service endpoints and Services matching no running pod
+	Services *ServicesStatus `json:"services,omitempty"`
+	// Unowned are the running and pending pods without ownerReferences or whose controller is gone, per namespace
+	Unowned []NamespaceUnownedPods `json:"unowned,omitempty"`
+	// Images lists the containers of running pods using images the spec does not allow
+	Images *ImagesStatus `json:"images,omitempty"`
+	// Quotas compares the pods and CPU requests of namespaces with their ResourceQuotas and forecasts their exhaustion
+	Quotas []QuotaForecast `json:"quotas,omitempty"`
+	// History summarizes the pod changes of the last hour
+	History *HistorySummary `json:"history,omitempty"`
+	// Churn are the owners whose pod creations in the last minute spiked above their baseline
+	Churn []ChurnAnomaly `json:"churn,omitempty"`
+	// Conditions report problems the monitor detected. Conditions with status
+	// True are the active alerts
+	Conditions []PodMonitorCondition `json:"conditions,omitempty"`
+}
+
+// ResourceStatus ...
+type ResourceStatus struct {
+	Total      ResourceUsage       `json:"total"`
+	Namespaces []ResourceBreakdown `json:"namespaces,omitempty"`
+	Owners     []ResourceBreakdown `json:"owners,omitempty"`
+	QOSClasses []ResourceBreakdown `json:"qosClasses,omitempty"`
+	// TruncatedOwners is the number of owners left out of Owners
+	TruncatedOwners int32 `json:"truncatedOwners,omitempty"`
+}
+
+// ResourceUsage ...
+type ResourceUsage struct {
+	Pods     int32                `json:"pods"`
+	Requests core_v1.ResourceList `json:"requests,omitempty"`
+	Limits   core_v1.ResourceList `json:"limits,omitempty"`
+}
+
+// ResourceBreakdown is the resource usage of a namespace, an owner
+// (`namespace/Kind/name`) or a QoS class
+type ResourceBreakdown struct {
+	Name          string `json:"name"`
+	ResourceUsage `json:",inline"`
+}
+
+// NamespaceSecurity counts the pods of a namespace that violate the baseline
+// and restricted Pod Security Standards, and the pods failing each check
+type NamespaceSecurity struct {
+	Namespace            string               `json:"namespace"`
+	Pods                 int32                `json:"pods"`
+	BaselineViolations   int32                `json:"baselineViolations"`
+	RestrictedViolations int32                `json:"restrictedViolations"`
+	Checks               []SecurityCheckCount `json:"checks,omitempty"`
+}
+
+// SecurityCheckCount ...
+type SecurityCheckCount struct {
+	Check string `json:"check"`
+	Level string `json:"level"`
+	Pods  int32  `json:"pods"`
+}
+
+// NodesStatus ...
+type NodesStatus struct {
+	RunningPods                int32        `json:"runningPods"`
+	AllocatablePods            int32        `json:"allocatablePods"`
+	UtilizationPercent         int32        `json:"utilizationPercent"`
+	NotReadyNodes              int32        `json:"notReadyNodes,omitempty"`
+	RunningPodsOnNotReadyNodes int32        `json:"runningPodsOnNotReadyNodes,omitempty"`
+	Nodes                      []NodeStatus `json:"nodes,omitempty"`
+	// TruncatedNodes is the number of nodes left out of Nodes
+	TruncatedNodes int32 `json:"truncatedNodes,omitempty"`
+}
+
+// NodeStatus ...
+type NodeStatus struct {
+	Name               string `json:"name"`
+	Ready              bool   `json:"ready"`
+	RunningPods        int32  `json:"runningPods"`
+	AllocatablePods    int32  `json:"allocatablePods"`
+	UtilizationPercent int32  `json:"utilizationPercent"`
+	NearPodLimit       bool   `json:"nearPodLimit,omitempty"`
+}
+
+// TopologyStatus ...
+type TopologyStatus struct {
+	Zones  int32         `json:"zones"`
+	Owners []OwnerSpread `json:"owners,omitempty"`
+	// TruncatedOwners is the number of owners left out of Owners
+	TruncatedOwners int32 `json:"truncatedOwners,omitempty"`
+}
+
+// OwnerSpread is the spread of the running replicas of an owner (`namespace/Kind/name`)
+type OwnerSpread struct {
+	Owner      string         `json:"owner"`
+	Replicas   int32          `json:"replicas"`
+	Nodes      int32          `json:"nodes"`
+	Zones      []ZoneReplicas `json:"zones,omitempty"`
+	SingleNode bool           `json:"singleNode,omitempty"`
+	SingleZone bool           `json:"singleZone,omitempty"`
+}
+
+// ZoneReplicas ...
+type ZoneReplicas struct {
+	Zone     string `json:"zone"`
+	Replicas int32  `json:"replicas"`
+}
+
+// RestartStatus ...
+type RestartStatus struct {
+	Restarts   int32              `json:"restarts"`
+	OOMKilled  int32              `json:"oomKilled"`
+	Namespaces []RestartBreakdown `json:"namespaces,omitempty"`
+	// TopOwners are the owners (`namespace/Kind/name`) with the most restarts
+	TopOwners []RestartBreakdown `json:"topOwners,omitempty"`
+}
+
+// RestartBreakdown is the restarts and terminations of a namespace or owner.
+// Terminations counts terminated containers by reason
+type RestartBreakdown struct {
+	Name                string           `json:"name"`
+	Restarts            int32            `json:"restarts"`
+	Terminations        map[string]int32 `json:"terminations,omitempty"`
+	LastReason          string           `json:"lastReason,omitempty"`
+	LastExitCode        int32            `json:"lastExitCode"`
+	LastTerminationTime meta_v1.Time     `json:"lastTerminationTime,omitempty"`
+}
+
+// SchedulingStatus ...
+type SchedulingStatus struct {
+	UnschedulablePods int32                 `json:"unschedulablePods"`
+	Reasons           []SchedulingReason    `json:"reasons,omitempty"`
+	Namespaces        []NamespaceScheduling `json:"namespaces,omitempty"`
+}
+
+// NamespaceScheduling ...
+type NamespaceScheduling struct {
+	Namespace         string             `json:"namespace"`
+	UnschedulablePods int32              `json:"unschedulablePods"`
+	Reasons           []SchedulingReason `json:"reasons,omitempty"`
+}
+
+// SchedulingReason is the number of unschedulable pods failing for a reason,
+// with one scheduler message as an example
+type SchedulingReason struct {
+	Reason  string `json:"reason"`
+	Pods    int32  `json:"pods"`
+	Example string `json:"example,omitempty"`
+}
+
+// EventStatus ...
+type EventStatus struct {
+	Reasons    []EventReason     `json:"reasons,omitempty"`
+	Namespaces []NamespaceEvents `json:"namespaces,omitempty"`
+}
+
+// NamespaceEvents ...
+type NamespaceEvents struct {
+	Namespace string        `json:"namespace"`
+	Reasons   []EventReason `json:"reasons,omitempty"`
+}
+
+// EventReason ...
+type EventReason struct {
+	Type   string `json:"type"`
+	Reason string `json:"reason"`
+	Count  int32  `json:"count"`
+}
+
+// JobsStatus ...
+type JobsStatus struct {
+	Window   string       `json:"window"`
+	Jobs     []JobOutcome `json:"jobs,omitempty"`
+	CronJobs []JobOutcome `json:"cronJobs,omitempty"`
+	// FailingCronJobs are the CronJobs whose last run failed
+	FailingCronJobs []string `json:"failingCronJobs,omitempty"`
+	// MissedCronJobs are the CronJobs that did not start a scheduled run
+	MissedCronJobs []string `json:"missedCronJobs,omitempty"`
+	// TruncatedJobs and TruncatedCronJobs are the number of outcomes left out of Jobs and CronJobs
+	TruncatedJobs     int32 `json:"truncatedJobs,omitempty"`
+	TruncatedCronJobs int32 `json:"truncatedCronJobs,omitempty"`
+}
+
+// JobOutcome is the outcome of the runs of a Job or CronJob (`namespace/name`)
+type JobOutcome struct {
+	Name                   string       `json:"name"`
+	Runs                   int32        `json:"runs"`
+	Succeeded              int32        `json:"succeeded"`
+	Failed                 int32        `json:"failed"`
+	SuccessRatePercent     int32        `json:"successRatePercent"`
+	AverageDurationSeconds int64        `json:"averageDurationSeconds"`
+	MaxDurationSeconds     int64        `json:"maxDurationSeconds"`
+	Retries                int32        `json:"retries"`
+	LastRunTime            meta_v1.Time `json:"lastRunTime"`
+	LastRunSucceeded       bool         `json:"lastRunSucceeded"`
+}
+
+// RolloutStatus is a rollout of a workload (`namespace/Kind/name`) from the
+// previous to the current revision
+type RolloutStatus struct {
+	Workload         string        `json:"workload"`
+	Revision         string        `json:"revision"`
+	PreviousRevision string        `json:"previousRevision,omitempty"`
+	Started          meta_v1.Time  `json:"started"`
+	Completed        *meta_v1.Time `json:"completed,omitempty"`
+	// DurationSeconds is set once the rollout completed
+	DurationSeconds int64 `json:"durationSeconds,omitempty"`
+	Stalled         bool  `json:"stalled,omitempty"`
+	// Unhealthy is set when pods of the new revision crash more often than the ones of the previous revision
+	Unhealthy bool           `json:"unhealthy,omitempty"`
+	New       RevisionHealth `json:"new"`
+	Previous  RevisionHealth `json:"previous"`
+}
+
+// ReplicaShortfall is a workload (`namespace/Kind/name`) with fewer ready
+// pods than desired, and since when it has been short
+type ReplicaShortfall struct {
+	Owner   string       `json:"owner"`
+	Desired int32        `json:"desired"`
+	Running int32        `json:"running"`
+	Ready   int32        `json:"ready"`
+	Since   meta_v1.Time `json:"since"`
+}
+
+// RevisionHealth ...
+type RevisionHealth struct {
+	Pods         int32 `json:"pods"`
+	ReadyPods    int32 `json:"readyPods"`
+	CrashingPods int32 `json:"crashingPods"`
+	Restarts     int32 `json:"restarts"`
+}
+
+// PolicyStatus ...
+type PolicyStatus struct {
+	ViolatingPods int32             `json:"violatingPods"`
+	Namespaces    []PolicyBreakdown `json:"namespaces,omitempty"`
+	Owners        []PolicyBreakdown `json:"owners,omitempty"`
+	// TruncatedOwners is the number of owners left out of Owners
+	TruncatedOwners int32 `json:"truncatedOwners,omitempty"`
+}
+
+// PolicyBreakdown is the number of pods of a namespace or owner
+// (`namespace/Kind/name`) violating the policy, and the pods failing each rule
+type PolicyBreakdown struct {
+	Name  string           `json:"name"`
+	Pods  int32            `json:"pods"`
+	Rules map[string]int32 `json:"rules,omitempty"`
+}
+
+// NamespaceNetworkCoverage ...
+type NamespaceNetworkCoverage struct {
+	Namespace          string `json:"namespace"`
+	RunningPods        int32  `json:"runningPods"`
+	UnprotectedIngress int32  `json:"unprotectedIngress"`
+	UnprotectedEgress  int32  `json:"unprotectedEgress"`
+}
+
+// NamespaceMissingReferences ...
+type NamespaceMissingReferences struct {
+	Namespace   string             `json:"namespace"`
+	BlockedPods int32              `json:"blockedPods"`
+	Missing     []MissingReference `json:"missing,omitempty"`
+}
+
+// MissingReference ...
+type MissingReference struct {
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+	Pods int32  `json:"pods,omitempty"`
+}
+
+// ServicesStatus ...
+type ServicesStatus struct {
+	NotReady  []NotReadyEndpoint `json:"notReady,omitempty"`
+	Unmatched []UnmatchedService `json:"unmatched,omitempty"`
+	// TruncatedNotReady and TruncatedUnmatched are the number of entries left out of NotReady and Unmatched
+	TruncatedNotReady  int32 `json:"truncatedNotReady,omitempty"`
+	TruncatedUnmatched int32 `json:"truncatedUnmatched,omitempty"`
+}
+
+// NotReadyEndpoint ...
+type NotReadyEndpoint struct {
+	Namespace string `json:"namespace"`
+	Service   string `json:"service"`
+	Pod       string `json:"pod"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+// UnmatchedService ...
+type UnmatchedService struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Selector  string `json:"selector"`
+}
+
+// NamespaceUnownedPods ...
+type NamespaceUnownedPods struct {
+	Namespace string       `json:"namespace"`
+	Naked     int32        `json:"naked"`
+	Orphaned  int32        `json:"orphaned"`
+	Pods      []UnownedPod `json:"pods,omitempty"`
+	// TruncatedPods is the number of pods left out of Pods
+	TruncatedPods int32 `json:"truncatedPods,omitempty"`
+}
+
+// UnownedPod ...
+type UnownedPod struct {
+	Name    string       `json:"name"`
+	Reason  string       `json:"reason"`
+	Owner   string       `json:"owner,omitempty"`
+	Created meta_v1.Time `json:"created"`
+}
+
+// ImagesStatus ...
+type ImagesStatus struct {
+	NonCompliantPods int32               `json:"nonCompliantPods"`
+	NonCompliant     []NonCompliantImage `json:"nonCompliant,omitempty"`
+	// TruncatedNonCompliant is the number of containers left out of NonCompliant
+	TruncatedNonCompliant int32 `json:"truncatedNonCompliant,omitempty"`
+}
+
+// NonCompliantImage ...
+type NonCompliantImage struct {
+	Namespace string `json:"namespace"`
+	Pod       string `json:"pod"`
+	Container string `json:"container"`
+	Image     string `json:"image"`
+	Reason    string `json:"reason"`
+}
+
+// QuotaForecast is the usage of the pods or requests.cpu quota of a namespace.
+// ExhaustedAt is when the quota was first seen exhausted or is forecast to run
+// out at the current growth
+type QuotaForecast struct {
+	Namespace   string            `json:"namespace"`
+	Resource    string            `json:"resource"`
+	Hard        resource.Quantity `json:"hard"`
+	Used        resource.Quantity `json:"used"`
+	UsedPercent int32             `json:"usedPercent"`
+	ExhaustedAt *meta_v1.Time     `json:"exhaustedAt,omitempty"`
+	AtRisk      bool              `json:"atRisk,omitempty"`
+}
+
+// HistorySummary sums the pod changes over the last WindowMinutes. The running
+// watermarks are the most and fewest pods running at once
+type HistorySummary struct {
+	WindowMinutes int32 `json:"windowMinutes"`
+	Created       int32 `json:"created"`
+	Deleted       int32 `json:"deleted"`
+	Failed        int32 `json:"failed"`
+	RunningHigh   int32 `json:"runningHigh"`
+	RunningLow    int32 `json:"runningLow"`
+}
+
+// ChurnAnomaly is an owner (`Kind/name`) that created far more pods in the last
+// minute than its EWMA Baseline of creations per minute, anomalous since Since
+type ChurnAnomaly struct {
+	Namespace string       `json:"namespace"`
+	Owner     string       `json:"owner"`
+	Creations int32        `json:"creations"`
+	Baseline  string       `json:"baseline"`
+	Since     meta_v1.Time `json:"since"`
+}
+
+// PodMonitorCondition ...
+type PodMonitorCondition struct {
+	Type               string                  `json:"type"`
+	Status             meta_v1.ConditionStatus `json:"status"`
+	Reason             string                  `json:"reason,omitempty"`
+	Message            string                  `json:"message,omitempty"`
+	LastTransitionTime meta_v1.Time            `json:"lastTransitionTime,omitempty"`
+}
+
+// NamespaceStatus ...
+type NamespaceStatus struct {
+	Namespace       string `json:"namespace"`
+	PodCreatedCount int32  `json:"podCreatedCount,omitempty"`
+	PodRunningCount int32  `json:"podRunningCount,omitempty"`
+	PodPendingCount int32  `json:"podPendingCount,omitempty"`
+	PodFailedCount  int32  `json:"podFailedCount,omitempty"`
 }
 
 // PodMonitorList ...